@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	sitter "github.com/alexaandru/go-tree-sitter-bare"
 	"github.com/spf13/cobra"
 	"github.com/tnaucoin/mintmpl/internal/spec"
+	"github.com/tnaucoin/mintmpl/internal/suggest"
 	"github.com/tnaucoin/mintmpl/internal/transformer"
 	"go.yaml.in/yaml/v3"
 )
@@ -33,8 +37,9 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
-	validateCmd := &cobra.Command{}
 	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(suggestCmd)
+	rootCmd.AddCommand(initCmd)
 	inspectCmd := &cobra.Command{}
 	rootCmd.AddCommand(inspectCmd)
 	rootCmd.AddCommand(versionCmd)
@@ -48,6 +53,327 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var (
+	valSource string
+	valSpec   string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate a spec file",
+	Long:  "Validate a spec file against the supported languages and their grammars",
+	RunE:  runValidate,
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&valSource, "source", "s", ".", "Source Directory")
+	validateCmd.Flags().StringVarP(&valSpec, "spec", "", "", "Path to spec file (Default: SOURCE/.mintmpl.yml)")
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	specFile := valSpec
+	if specFile == "" {
+		specFile = filepath.Join(valSource, ".mintmpl.yml")
+	}
+
+	templateSpec, err := spec.Load(specFile)
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
+	}
+	if err := templateSpec.ApplyEcosystems(valSource); err != nil {
+		return err
+	}
+
+	problems := templateSpec.Validate()
+	for _, p := range problems {
+		fmt.Printf("::error::%s\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("spec %s has %d problem(s)", specFile, len(problems))
+	}
+
+	fmt.Printf("Spec %s is valid\n", specFile)
+	return nil
+}
+
+var (
+	sugSource string
+	sugSpec   string
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest",
+	Short: "Suggest variables from project manifests",
+	Long:  "Suggest variables from go.mod, package.json, pyproject.toml, Cargo.toml, .csproj, pom.xml, LICENSE and git config, printing a spec snippet with transforms for where their values occur",
+	RunE:  runSuggest,
+}
+
+func init() {
+	suggestCmd.Flags().StringVarP(&sugSource, "source", "s", ".", "Source Directory")
+	suggestCmd.Flags().StringVarP(&sugSpec, "spec", "", "", "Path to spec file for excludes and languages (Default: SOURCE/.mintmpl.yml if present)")
+}
+
+func runSuggest(cmd *cobra.Command, args []string) error {
+	source, err := filepath.Abs(sugSource)
+	if err != nil {
+		return fmt.Errorf("resolving source path: %w", err)
+	}
+
+	// an existing spec contributes its excludes and languages
+	templateSpec := &spec.Spec{}
+	specFile := sugSpec
+	if specFile == "" {
+		specFile = filepath.Join(source, ".mintmpl.yml")
+		if _, err := os.Stat(specFile); err != nil {
+			specFile = ""
+		}
+	}
+	if specFile != "" {
+		if templateSpec, err = spec.Load(specFile); err != nil {
+			return fmt.Errorf("loading spec: %w", err)
+		}
+	}
+
+	candidates := suggest.Scan(source)
+	if len(candidates) == 0 {
+		return fmt.Errorf("no manifests with candidate values found in %s", source)
+	}
+	suggestions, err := suggest.Count(source, templateSpec, candidates)
+	if err != nil {
+		return fmt.Errorf("walking source directory: %w", err)
+	}
+	if len(suggestions) == 0 {
+		return fmt.Errorf("none of the %d candidate values occur in %s", len(candidates), source)
+	}
+
+	snippet, err := suggest.Render(suggestions)
+	if err != nil {
+		return fmt.Errorf("rendering snippet: %w", err)
+	}
+	fmt.Print(string(snippet))
+	return nil
+}
+
+var (
+	initSource  string
+	initOutput  string
+	initForce   bool
+	initYes     bool
+	initVars    []string
+	initAnswers string
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Scaffold a spec file",
+	Long:  "Scaffold a commented .mintmpl.yml by detecting languages and build folders to exclude, and asking which values to template. Answers can be given with --var, --answers or --yes instead of prompts.",
+	RunE:  runInit,
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initSource, "source", "s", ".", "Source Directory")
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "", "Path of the spec file to write (Default: SOURCE/.mintmpl.yml)")
+	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "Overwrite an existing spec file")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Accept the detected excludes and suggested variables without prompting")
+	initCmd.Flags().StringArrayVarP(&initVars, "var", "", nil, "Value to template as name=value, without prompting (repeatable)")
+	initCmd.Flags().StringVarP(&initAnswers, "answers", "", "", "YAML file with variables (name: value) and exclude patterns, without prompting")
+}
+
+// initAnswerFile holds the answers of a non-interactive init
+type initAnswerFile struct {
+	Variables map[string]string `yaml:"variables"`
+	// Exclude replaces the detected folders when given
+	Exclude []string `yaml:"exclude"`
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	source, err := filepath.Abs(initSource)
+	if err != nil {
+		return fmt.Errorf("resolving source path: %w", err)
+	}
+	output := initOutput
+	if output == "" {
+		output = filepath.Join(source, ".mintmpl.yml")
+	}
+	if _, err := os.Stat(output); err == nil && !initForce {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	templateSpec := &spec.Spec{}
+	layout, err := suggest.Survey(source, templateSpec)
+	if err != nil {
+		return fmt.Errorf("walking source directory: %w", err)
+	}
+	fmt.Printf("Found %d files in %s\n", layout.Files, source)
+	for _, lang := range layout.LanguageNames() {
+		fmt.Printf("\t%s: %d\n", lang, layout.Languages[lang])
+	}
+	fmt.Println()
+
+	var selected []suggest.Suggestion
+	var excludes []string
+	if initYes || len(initVars) > 0 || initAnswers != "" {
+		selected, excludes, err = initFromAnswers(source, templateSpec, layout)
+	} else {
+		selected, excludes, err = initInteractive(cmd, source, templateSpec, layout)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := suggest.RenderSpec(filepath.Base(source), layout, selected, excludes)
+	if err != nil {
+		return fmt.Errorf("rendering spec: %w", err)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("writing spec: %w", err)
+	}
+	fmt.Printf("Wrote %s with %d variable(s)\n", output, len(selected))
+	return nil
+}
+
+func initFromAnswers(source string, templateSpec *spec.Spec, layout *suggest.Layout) ([]suggest.Suggestion, []string, error) {
+	var answers initAnswerFile
+	if initAnswers != "" {
+		data, err := os.ReadFile(initAnswers)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading answers: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &answers); err != nil {
+			return nil, nil, fmt.Errorf("parsing answers %w", err)
+		}
+	}
+
+	excludes := layout.Excludes
+	if answers.Exclude != nil {
+		excludes = answers.Exclude
+	}
+	templateSpec.Exclude = excludes
+
+	var candidates []suggest.Candidate
+	if initYes {
+		candidates = suggest.Scan(source)
+	}
+	given := make(map[string]string)
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(answers.Variables)) {
+		given[name] = answers.Variables[name]
+		names = append(names, name)
+	}
+	for _, v := range initVars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" || value == "" {
+			return nil, nil, fmt.Errorf("--var %q: want name=value", v)
+		}
+		if _, ok := given[name]; !ok {
+			names = append(names, name)
+		}
+		given[name] = value
+	}
+	// given values win over suggestions for the same variable or value
+	candidates = slices.DeleteFunc(candidates, func(c suggest.Candidate) bool {
+		_, ok := given[c.Variable]
+		return ok || slices.Contains(slices.Collect(maps.Values(given)), c.Value)
+	})
+	for _, name := range names {
+		candidates = append(candidates, suggest.Candidate{Variable: name, Value: given[name]})
+	}
+
+	suggestions, err := suggest.Count(source, templateSpec, candidates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("walking source directory: %w", err)
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(suggestions, func(s suggest.Suggestion) bool { return s.Variable == name }) {
+			fmt.Printf("::warning::%s: %q does not occur in the source\n", name, given[name])
+		}
+	}
+	for _, s := range suggestions {
+		printSuggestion(s)
+	}
+	return suggestions, excludes, nil
+}
+
+func initInteractive(cmd *cobra.Command, source string, templateSpec *spec.Spec, layout *suggest.Layout) ([]suggest.Suggestion, []string, error) {
+	in := bufio.NewReader(cmd.InOrStdin())
+
+	var excludes []string
+	for _, pattern := range layout.Excludes {
+		if answer := prompt(in, fmt.Sprintf("Exclude %s? [Y/n]", pattern), "y"); strings.HasPrefix(strings.ToLower(answer), "y") {
+			excludes = append(excludes, pattern)
+		}
+	}
+	templateSpec.Exclude = excludes
+
+	suggestions, err := suggest.Count(source, templateSpec, suggest.Scan(source))
+	if err != nil {
+		return nil, nil, fmt.Errorf("walking source directory: %w", err)
+	}
+
+	var selected []suggest.Suggestion
+	used := func(name string) bool {
+		return slices.ContainsFunc(selected, func(s suggest.Suggestion) bool { return s.Variable == name })
+	}
+	for _, s := range suggestions {
+		printSuggestion(s)
+		name := prompt(in, fmt.Sprintf("Variable for %q [%s, - to skip]", s.Value, s.Variable), s.Variable)
+		if name == "-" {
+			continue
+		}
+		if used(name) {
+			fmt.Printf("Variable %s is already used, skipped\n", name)
+			continue
+		}
+		s.Variable = name
+		selected = append(selected, s)
+	}
+
+	for {
+		value := prompt(in, "Another value to template (empty to finish)", "")
+		if value == "" {
+			break
+		}
+		name := prompt(in, "Variable name", "")
+		if name == "" || used(name) {
+			fmt.Println("A new variable name is needed, skipped")
+			continue
+		}
+		found, err := suggest.Count(source, templateSpec, []suggest.Candidate{{Variable: name, Value: value}})
+		if err != nil {
+			return nil, nil, fmt.Errorf("walking source directory: %w", err)
+		}
+		if len(found) == 0 {
+			fmt.Printf("%q does not occur in the source, skipped\n", value)
+			continue
+		}
+		printSuggestion(found[0])
+		selected = append(selected, found[0])
+	}
+	return selected, excludes, nil
+}
+
+// prompt asks a question and returns the trimmed answer, or def when the
+// answer is empty or the input has ended
+func prompt(in *bufio.Reader, question, def string) string {
+	fmt.Printf("%s: ", question)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Println()
+		return def
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+func printSuggestion(s suggest.Suggestion) {
+	fmt.Printf("\n%q: %d match(es), %s\n", s.Value, s.Total(), suggest.Describe(s))
+	for _, sample := range s.Samples {
+		fmt.Printf("\t%s\n", sample)
+	}
+}
+
 var (
 	genSource       string
 	genOutput       string
@@ -87,6 +413,9 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("loading spec: %w", err)
 	}
+	if err := templateSpec.ApplyEcosystems(source); err != nil {
+		return err
+	}
 
 	fmt.Printf("Source: %s\n", source)
 	fmt.Printf("Output: %s\n", output)
@@ -103,7 +432,11 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	transforms := templateSpec.BuildTransforms()
-	trans := transformer.New(transforms)
+	trans := transformer.New(transforms, templateSpec.BuildRepeats())
+	trans.SetLanguages(templateSpec.LanguageTable())
+	trans.SetAutoLanguages(templateSpec.AutoLanguages)
+	trans.SetLanguageOverrides(templateSpec.LanguageOverrides)
+	trans.SetStripNotebookOutputs(templateSpec.StripNotebookOutputs)
 
 	excludes := append(spec.GetDefaultExcludes(), templateSpec.Exclude...)
 
@@ -120,7 +453,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		if shouldExclude(relPath, excludes) {
+		if spec.MatchPath(relPath, excludes) {
 			if d.IsDir() {
 				return filepath.SkipDir
 			}
@@ -138,7 +471,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			return nil
 		}
 
-		destPath := filepath.Join(templateDir, relPath)
+		destPath := filepath.Join(templateDir, templateSpec.TemplatePath(relPath))
 		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
 			return fmt.Errorf("creating directory for %s: %w", relPath, err)
 		}
@@ -147,7 +480,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			return os.WriteFile(destPath, content, 0644)
 		}
 
-		transformed, wasTransformed := trans.TransformFile(path, content)
+		transformed, wasTransformed := trans.TransformFile(relPath, content)
 
 		if wasTransformed {
 			filesTransformed++
@@ -161,6 +494,17 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("walking source directory: %w", err)
 	}
 
+	// variables introduced by inline directives become questions unless the
+	// spec already defines them
+	for name, def := range trans.DirectiveVariables() {
+		if templateSpec.Variables == nil {
+			templateSpec.Variables = make(map[string]*spec.VariableConfig)
+		}
+		if _, ok := templateSpec.Variables[name]; !ok {
+			templateSpec.Variables[name] = &spec.VariableConfig{Type: "str", Default: def}
+		}
+	}
+
 	if err := generateCopierYAML(templateSpec, output); err != nil {
 		return fmt.Errorf("generating copier yaml: %w", err)
 	}
@@ -185,29 +529,6 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func shouldExclude(path string, patterns []string) bool {
-	for _, pattern := range patterns {
-		if path == pattern {
-			return true
-		}
-
-		if strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, "/**") {
-			prefix := strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/")
-			if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
-				return true
-			}
-		}
-
-		if matched, _ := filepath.Match(pattern, path); matched {
-			return true
-		}
-		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return true
-		}
-	}
-	return false
-}
-
 func shouldSkipTransform(path string, patterns []string) bool {
 	for _, pattern := range patterns {
 		if matched, _ := filepath.Match(pattern, path); matched {
@@ -228,17 +549,36 @@ func generateCopierYAML(s *spec.Spec, outputDir string) error {
 	config["_jinja_extensions"] = []string{"jinja2_time.TimeExtension"}
 
 	for name, varConfig := range s.Variables {
+		// list variables are asked as a multiselect when they have choices,
+		// otherwise as a yaml list
+		varType := varConfig.Type
+		if varType == "" && len(varConfig.Repeat) > 0 {
+			varType = "yaml"
+			if len(varConfig.Choices) > 0 {
+				varType = "str"
+			}
+		}
 		varDef := map[string]interface{}{
-			"type":    varConfig.Type,
+			"type":    varType,
 			"help":    varConfig.Description,
 			"default": varConfig.Default,
 		}
+		if varConfig.When != nil {
+			varDef["when"] = varConfig.When
+		}
 		if len(varConfig.Choices) > 0 {
 			varDef["choices"] = varConfig.Choices
+			if len(varConfig.Repeat) > 0 {
+				varDef["multiselect"] = true
+			}
 		}
 		config[name] = varDef
 	}
 
+	if len(s.Tasks) > 0 {
+		config["_tasks"] = s.Tasks
+	}
+
 	if len(s.ConditionalPaths) > 0 {
 		var excludes []string
 		for pathPattern, condition := range s.ConditionalPaths {
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+	"go.yaml.in/yaml/v3"
+)
+
+// writeTree writes files to a temporary source tree and returns its path
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	source := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(source, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source
+}
+
+// generate runs the generate command on a source tree holding files and
+// returns the output directory
+func generate(t *testing.T, files map[string]string) string {
+	t.Helper()
+	source := writeTree(t, files)
+	genSource, genOutput, genSpec = source, filepath.Join(t.TempDir(), "out"), ""
+	if err := runGenerate(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return genOutput
+}
+
+// readCopierYAML returns the questions of a generated copier.yaml
+func readCopierYAML(t *testing.T, output string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(output, "copier.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestDirectiveVariablesBecomeQuestions(t *testing.T) {
+	output := generate(t, map[string]string{
+		".mintmpl.yml": "name: demo\n" +
+			"variables:\n" +
+			"  project_name:\n" +
+			"    type: str\n" +
+			"    description: Project name\n" +
+			"    default: widget\n",
+		"main.go": "package main\n\n" +
+			"// mintmpl:var service_name\n" +
+			"const service = \"billing\"\n\n" +
+			"// mintmpl:var project_name\n" +
+			"const project = \"acme\"\n",
+	})
+
+	got, err := os.ReadFile(filepath.Join(output, "template", "main.go.jinja"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\n" +
+		"const service = \"{{ service_name }}\"\n\n" +
+		"const project = \"{{ project_name }}\"\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	config := readCopierYAML(t, output)
+	question, _ := config["service_name"].(map[string]any)
+	if question["type"] != "str" || question["default"] != "billing" {
+		t.Errorf("service_name question = %v, want a str defaulting to billing", question)
+	}
+	// variables declared in the spec keep their definition
+	question, _ = config["project_name"].(map[string]any)
+	if question["default"] != "widget" || question["help"] != "Project name" {
+		t.Errorf("project_name question = %v, want the spec's", question)
+	}
+}
+
+func TestInitFromAnswers(t *testing.T) {
+	source := writeTree(t, map[string]string{
+		"go.mod": "module github.com/acme/widget\n\ngo 1.22\n",
+		"main.go": "package main\n\n" +
+			"import \"github.com/acme/widget/internal/cli\"\n\n" +
+			"func main() { cli.Run(\"widget\") }\n",
+		"dist/widget":     "binary\n",
+		"build/notes.txt": "widget\n",
+	})
+	initSource, initOutput, initForce, initYes, initVars = source, "", false, false, nil
+	initAnswers = filepath.Join(t.TempDir(), "answers.yml")
+	answers := "variables:\n  project_name: widget\n  org: acme\n  missing: nowhere\n"
+	if err := os.WriteFile(initAnswers, []byte(answers), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runInit(initCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := spec.Load(filepath.Join(source, ".mintmpl.yml"))
+	if err != nil {
+		t.Fatalf("loading generated spec: %v", err)
+	}
+	if s.Name != filepath.Base(source) {
+		t.Errorf("name = %q, want %q", s.Name, filepath.Base(source))
+	}
+	for name, value := range map[string]string{"project_name": "widget", "org": "acme"} {
+		v := s.Variables[name]
+		if v == nil || v.Default != value || len(v.Transforms) != 1 || v.Transforms[0].Match != value {
+			t.Errorf("%s = %+v, want a variable defaulting to and matching %q", name, v, value)
+		}
+	}
+	// values occurring nowhere are left out
+	if _, ok := s.Variables["missing"]; ok {
+		t.Errorf("missing variable declared, want it left out")
+	}
+	for _, want := range []string{"dist/", "build/"} {
+		if !slices.Contains(s.Exclude, want) {
+			t.Errorf("exclude = %v, want %s detected", s.Exclude, want)
+		}
+	}
+
+	// a second run needs --force
+	if err := runInit(initCmd, nil); err == nil {
+		t.Error("init overwrote the existing spec without --force")
+	}
+}
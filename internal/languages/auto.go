@@ -0,0 +1,77 @@
+package languages
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	forest "github.com/alexaandru/go-sitter-forest"
+)
+
+// captureRx matches simple highlight captures such as `(string) @string`
+var captureRx = regexp.MustCompile(`\(([a-z_][a-z0-9_]*)\)\s*@([a-z][\w.]*)`)
+
+// captureCategories maps highlight capture names to categories
+var captureCategories = map[string]NodeCategory{
+	"string":    CategoryString,
+	"comment":   CategoryComment,
+	"variable":  CategoryIdentifier,
+	"module":    CategoryNamespace,
+	"namespace": CategoryNamespace,
+}
+
+var (
+	autoMu        sync.Mutex
+	autoLanguages = make(map[string]*LanguageConfig)
+)
+
+// DetectLanguage picks a go-sitter-forest grammar for a file that is not in
+// the language table and derives its categories heuristically. It returns
+// nil when no grammar fits.
+func DetectLanguage(path string) *LanguageConfig {
+	name := forest.DetectLanguage(path)
+	if name == "" || name == "unknown" || !forest.SupportedLanguage(name) {
+		return nil
+	}
+	return AutoLanguage(name)
+}
+
+// AutoLanguage builds a language config for a grammar from its node type
+// names and, where available, its highlight queries.
+func AutoLanguage(grammar string) *LanguageConfig {
+	autoMu.Lock()
+	defer autoMu.Unlock()
+
+	if lc, ok := autoLanguages[grammar]; ok {
+		return lc
+	}
+
+	lc := &LanguageConfig{
+		Name:     grammar,
+		Language: forest.GetLanguage(grammar),
+	}
+	nodeTypes := lc.NodeTypes()
+	for _, nt := range nodeTypes {
+		switch {
+		case strings.Contains(nt, "comment"):
+			lc.OverrideCategory(CategoryComment, []string{nt}, nil)
+		case strings.Contains(nt, "string"):
+			lc.OverrideCategory(CategoryString, []string{nt}, nil)
+		case strings.Contains(nt, "identifier"):
+			lc.OverrideCategory(CategoryIdentifier, []string{nt}, nil)
+		}
+	}
+
+	for _, m := range captureRx.FindAllStringSubmatch(string(forest.GetQuery(grammar, "highlights")), -1) {
+		nodeType, capture := m[1], m[2]
+		cat, ok := captureCategories[strings.SplitN(capture, ".", 2)[0]]
+		if !ok || !slices.Contains(nodeTypes, nodeType) || lc.GetNodeCategory(nodeType) != "" {
+			continue
+		}
+		lc.OverrideCategory(cat, []string{nodeType}, nil)
+	}
+
+	autoLanguages[grammar] = lc
+	return lc
+}
@@ -0,0 +1,202 @@
+package languages
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	forest "github.com/alexaandru/go-sitter-forest"
+)
+
+// wellKnownFilenames maps extensionless filenames to language names
+var wellKnownFilenames = map[string]string{
+	"Dockerfile":    "dockerfile",
+	"Containerfile": "dockerfile",
+	"Makefile":      "make",
+	"GNUmakefile":   "make",
+	"makefile":      "make",
+	"Jenkinsfile":   "groovy",
+	"Gemfile":       "ruby",
+	"Rakefile":      "ruby",
+	"Vagrantfile":   "ruby",
+	"Brewfile":      "ruby",
+	"Podfile":       "ruby",
+	"Justfile":      "just",
+	"justfile":      "just",
+	"BUILD":         "starlark",
+	"WORKSPACE":     "starlark",
+	"Tiltfile":      "starlark",
+	".bashrc":       "bash",
+	".bash_profile": "bash",
+	".profile":      "bash",
+	".zshrc":        "bash",
+}
+
+// interpreters maps shebang interpreters to language names
+var interpreters = map[string]string{
+	"sh":      "bash",
+	"bash":    "bash",
+	"zsh":     "bash",
+	"ksh":     "bash",
+	"dash":    "bash",
+	"python":  "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"tsx":     "typescript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+	"elixir":  "elixir",
+	"groovy":  "groovy",
+	"kotlin":  "kotlin",
+	"scala":   "scala",
+	"make":    "make",
+	"fish":    "fish",
+}
+
+// aliases maps editor filetype and grammar names to language names
+var aliases = map[string]string{
+	"sh":       "bash",
+	"shell":    "bash",
+	"zsh":      "bash",
+	"py":       "python",
+	"js":       "javascript",
+	"ts":       "typescript",
+	"cs":       "csharp",
+	"c_sharp":  "csharp",
+	"rs":       "rust",
+	"c++":      "cpp",
+	"cxx":      "cpp",
+	"kt":       "kotlin",
+	"kts":      "kotlin",
+	"gradle":   "groovy",
+	"rb":       "ruby",
+	"ex":       "elixir",
+	"exs":      "elixir",
+	"protobuf": "proto",
+	"gql":      "graphql",
+	"htm":      "html",
+	"postcss":  "css",
+	"env":      "dotenv",
+	"yml":      "yaml",
+	"md":       "markdown",
+	"makefile": "make",
+	"text":     "plaintext",
+	"txt":      "plaintext",
+}
+
+var (
+	shebangRx     = regexp.MustCompile(`^#!\s*(\S+)(?:\s+(.*))?`)
+	versionRx     = regexp.MustCompile(`[0-9.]+$`)
+	vimModelineRx = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:filetype|ft|syntax)=([\w+-]+)`)
+	emacsModeRx   = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*([\w+-]+).*?|([\w+-]+))\s*-\*-`)
+)
+
+// modelines are looked for in this many lines at the start and end of a
+// file, within a window of bytes
+const (
+	modelineLines  = 5
+	modelineWindow = 2048
+)
+
+// Lookup returns the configuration for a language name, resolving common
+// aliases and grammar names.
+func (t *Table) Lookup(name string) *LanguageConfig {
+	return t.Languages[canonicalName(name)]
+}
+
+// ResolveLanguage returns the configuration for a language name, falling
+// back to a heuristic config built from the go-sitter-forest grammar of the
+// same name when the language is not in the table.
+func (t *Table) ResolveLanguage(name string) *LanguageConfig {
+	name = canonicalName(name)
+	if lc, ok := t.Languages[name]; ok {
+		return lc
+	}
+	if forest.SupportedLanguage(name) {
+		return AutoLanguage(name)
+	}
+	return nil
+}
+
+func canonicalName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// DetectModeline returns the language named by a Vim or Emacs modeline in
+// the first or last lines of content.
+func DetectModeline(content []byte) string {
+	head := content
+	if len(head) > modelineWindow {
+		head = head[:modelineWindow]
+	}
+	tail := content
+	if len(tail) > modelineWindow {
+		tail = tail[len(tail)-modelineWindow:]
+	}
+
+	lines := bytes.SplitN(head, []byte("\n"), modelineLines+1)
+	if len(lines) > modelineLines {
+		lines = lines[:modelineLines]
+	}
+	tailLines := bytes.Split(tail, []byte("\n"))
+	if len(tailLines) > modelineLines {
+		tailLines = tailLines[len(tailLines)-modelineLines:]
+	}
+
+	for _, line := range append(lines, tailLines...) {
+		if m := vimModelineRx.FindSubmatch(line); m != nil {
+			return string(m[1])
+		}
+		if m := emacsModeRx.FindSubmatch(line); m != nil {
+			if len(m[1]) > 0 {
+				return string(m[1])
+			}
+			return string(m[2])
+		}
+	}
+	return ""
+}
+
+// DetectShebang returns the language of the interpreter named in a shebang line
+func DetectShebang(content []byte) string {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	m := shebangRx.FindSubmatch(bytes.TrimSpace(line))
+	if m == nil {
+		return ""
+	}
+
+	interpreter := filepath.Base(string(m[1]))
+	if interpreter == "env" {
+		// skip env options such as -S and take the first program name
+		interpreter = ""
+		for _, arg := range strings.Fields(string(m[2])) {
+			if !strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") {
+				interpreter = filepath.Base(arg)
+				break
+			}
+		}
+	}
+	return interpreters[versionRx.ReplaceAllString(interpreter, "")]
+}
+
+// DetectWellKnownFilename returns the language of well-known extensionless
+// files such as Dockerfile or Makefile
+func DetectWellKnownFilename(path string) string {
+	base := filepath.Base(path)
+	if name, ok := wellKnownFilenames[base]; ok {
+		return name
+	}
+	if strings.HasPrefix(base, "Dockerfile.") || strings.HasSuffix(base, ".Dockerfile") {
+		return "dockerfile"
+	}
+	return ""
+}
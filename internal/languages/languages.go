@@ -1,6 +1,8 @@
 package languages
 
 import (
+	"fmt"
+	"maps"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -22,15 +24,48 @@ const (
 )
 
 type LanguageConfig struct {
-	Name            string
-	Extensions      []string
-	Filenames       []string
-	Language        *sitter.Language
+	Name       string
+	Extensions []string
+	Filenames  []string
+	Language   *sitter.Language
+	// Grammar names the go-sitter-forest grammar of a language declared in
+	// a spec or the global config; it is empty for built-in languages.
+	Grammar         string
 	StringTypes     []string
 	IdentifierTypes []string
 	NamespaceTypes  []string
 	ClassTypes      []string
 	CommentTypes    []string
+	// Categories holds node types for categories defined outside the
+	// built-in ones, keyed by category name.
+	Categories map[NodeCategory][]string
+	// Injections describes code of other languages embedded in this one
+	Injections []Injection
+}
+
+// Injection describes code of another language embedded in a host node
+type Injection struct {
+	// NodeType is the host node type holding the embedded code
+	NodeType string
+	// Language of the embedded code, used when LanguageNode is absent
+	Language string
+	// LanguageNode is a child node type whose text names the language,
+	// such as a fenced code block's info string
+	LanguageNode string
+	// LanguageAttribute is an attribute of the host element whose value
+	// names the language, such as <script lang="ts">; Language is used when
+	// the attribute is missing
+	LanguageAttribute string
+	// ContentNode is the child node type holding the code; the host node
+	// itself when empty
+	ContentNode string
+	// Key restricts the injection to mapping pairs with this key, whose
+	// value holds the code
+	Key string
+	// Contains restricts the injection to code containing this text
+	Contains string
+	// Paths restricts the injection to files matching these globs
+	Paths []string
 }
 
 var Languages = map[string]*LanguageConfig{
@@ -91,6 +126,22 @@ var Languages = map[string]*LanguageConfig{
 		ClassTypes:      []string{"type_declaration"},
 		CommentTypes:    []string{"comment"},
 	},
+	"gomod": {
+		Name:           "gomod",
+		Filenames:      []string{"go.mod"},
+		Language:       forest.GetLanguage("gomod"),
+		StringTypes:    []string{"file_path"},
+		NamespaceTypes: []string{"module_path"},
+		CommentTypes:   []string{"comment"},
+	},
+	"gowork": {
+		Name:           "gowork",
+		Filenames:      []string{"go.work"},
+		Language:       forest.GetLanguage("gowork"),
+		StringTypes:    []string{"file_path"},
+		NamespaceTypes: []string{"module_path"},
+		CommentTypes:   []string{"comment"},
+	},
 	"yaml": {
 		Name:            "yaml",
 		Extensions:      []string{".yaml", ".yml"},
@@ -98,10 +149,14 @@ var Languages = map[string]*LanguageConfig{
 		StringTypes:     []string{"string_scalar", "double_quote_scalar", "single_quote_scalar", "block_scalar"},
 		IdentifierTypes: []string{"flow_node"},
 		CommentTypes:    []string{"comment"},
+		Injections: []Injection{
+			{NodeType: "block_mapping_pair", Key: "run", Language: "bash", Paths: []string{".github/workflows/**", "action.yml", "action.yaml"}},
+		},
 	},
 	"toml": {
 		Name:            "toml",
 		Extensions:      []string{".toml"},
+		Filenames:       []string{"Cargo.lock"},
 		Language:        forest.GetLanguage("toml"),
 		StringTypes:     []string{"string", "multi_line_string"},
 		IdentifierTypes: []string{"bare_key"},
@@ -132,20 +187,271 @@ var Languages = map[string]*LanguageConfig{
 		StringTypes:     []string{"inline", "text", "code_span", "link_text"},
 		IdentifierTypes: []string{"link_destination"},
 		CommentTypes:    []string{"html_comment"},
+		Injections: []Injection{
+			{NodeType: "fenced_code_block", LanguageNode: "language", ContentNode: "code_fence_content"},
+		},
 	},
 	"ini": {
 		Name:            "ini",
 		Extensions:      []string{".ini", ".editorconfig", ".gitconfig"},
-		Filenames:       []string{".editorconfig", ".gitconfig"},
+		Filenames:       []string{".editorconfig", ".gitconfig", "setup.cfg", ".flake8", ".coveragerc", ".pylintrc"},
 		Language:        forest.GetLanguage("ini"),
 		StringTypes:     []string{"setting_value"},
 		IdentifierTypes: []string{"setting_name", "section_name"},
 		CommentTypes:    []string{"comment"},
 	},
+	"rust": {
+		Name:            "rust",
+		Extensions:      []string{".rs"},
+		Language:        forest.GetLanguage("rust"),
+		StringTypes:     []string{"string_literal", "raw_string_literal", "char_literal"},
+		IdentifierTypes: []string{"identifier", "type_identifier", "field_identifier"},
+		NamespaceTypes:  []string{"mod_item", "use_declaration"},
+		ClassTypes:      []string{"struct_item", "enum_item", "trait_item", "impl_item"},
+		CommentTypes:    []string{"line_comment", "block_comment"},
+	},
+	"c": {
+		Name:            "c",
+		Extensions:      []string{".c", ".h"},
+		Language:        forest.GetLanguage("c"),
+		StringTypes:     []string{"string_literal", "char_literal", "system_lib_string", "preproc_arg"},
+		IdentifierTypes: []string{"identifier", "type_identifier", "field_identifier"},
+		ClassTypes:      []string{"struct_specifier", "enum_specifier", "union_specifier", "type_definition"},
+		CommentTypes:    []string{"comment"},
+	},
+	"cpp": {
+		Name:            "cpp",
+		Extensions:      []string{".cpp", ".cc", ".cxx", ".c++", ".hpp", ".hh", ".hxx", ".h++"},
+		Language:        forest.GetLanguage("cpp"),
+		StringTypes:     []string{"string_literal", "raw_string_literal", "char_literal", "system_lib_string", "preproc_arg"},
+		IdentifierTypes: []string{"identifier", "type_identifier", "field_identifier", "namespace_identifier"},
+		NamespaceTypes:  []string{"namespace_definition", "using_declaration"},
+		ClassTypes:      []string{"class_specifier", "struct_specifier", "enum_specifier", "union_specifier"},
+		CommentTypes:    []string{"comment"},
+	},
+	"hcl": {
+		Name:            "hcl",
+		Extensions:      []string{".tf", ".tfvars", ".hcl"},
+		Language:        forest.GetLanguage("hcl"),
+		StringTypes:     []string{"template_literal"},
+		IdentifierTypes: []string{"identifier", "block.string_lit"},
+		ClassTypes:      []string{"block"},
+		CommentTypes:    []string{"comment"},
+	},
+	"dockerfile": {
+		Name:       "dockerfile",
+		Extensions: []string{".dockerfile"},
+		Filenames:  []string{"Dockerfile", "Containerfile"},
+		Language:   forest.GetLanguage("dockerfile"),
+		StringTypes: []string{
+			"env_pair.value", "label_pair.value", "arg_instruction.default",
+			"double_quoted_string", "single_quoted_string", "json_string", "shell_fragment", "path",
+		},
+		IdentifierTypes: []string{"env_pair.name", "label_pair.key", "arg_instruction.name", "image_name", "image_alias"},
+		CommentTypes:    []string{"comment"},
+	},
+	"bash": {
+		Name:            "bash",
+		Extensions:      []string{".sh", ".bash"},
+		Filenames:       []string{".bashrc", ".bash_profile", ".profile", ".envrc"},
+		Language:        forest.GetLanguage("bash"),
+		StringTypes:     []string{"string_content", "raw_string", "ansi_c_string", "heredoc_body"},
+		IdentifierTypes: []string{"variable_name", "function_definition.name"},
+		CommentTypes:    []string{"comment"},
+	},
+	"make": {
+		Name:            "make",
+		Extensions:      []string{".mk", ".make"},
+		Filenames:       []string{"Makefile", "GNUmakefile", "makefile"},
+		Language:        forest.GetLanguage("make"),
+		StringTypes:     []string{"text", "shell_text"},
+		IdentifierTypes: []string{"targets.word", "prerequisites.word", "variable_assignment.name", "variable_reference.word"},
+		CommentTypes:    []string{"comment"},
+	},
+	"kotlin": {
+		Name:            "kotlin",
+		Extensions:      []string{".kt", ".kts"},
+		Language:        forest.GetLanguage("kotlin"),
+		StringTypes:     []string{"string_content", "character_literal"}, // string_content keeps $name and ${expr} templates intact
+		IdentifierTypes: []string{"simple_identifier", "type_identifier"},
+		NamespaceTypes:  []string{"package_header", "import_header"},
+		ClassTypes:      []string{"class_declaration", "object_declaration"},
+		CommentTypes:    []string{"line_comment", "multiline_comment"},
+	},
+	"scala": {
+		Name:            "scala",
+		Extensions:      []string{".scala", ".sc", ".sbt"},
+		Language:        forest.GetLanguage("scala"),
+		StringTypes:     []string{"string", "interpolated_string", "character_literal"},
+		IdentifierTypes: []string{"identifier", "type_identifier"},
+		NamespaceTypes:  []string{"package_clause", "import_declaration"},
+		ClassTypes:      []string{"class_definition", "object_definition", "trait_definition", "enum_definition"},
+		CommentTypes:    []string{"comment", "block_comment"},
+	},
+	"groovy": {
+		Name:            "groovy",
+		Extensions:      []string{".groovy", ".gvy", ".gradle"},
+		Filenames:       []string{"Jenkinsfile"},
+		Language:        forest.GetLanguage("groovy"),
+		StringTypes:     []string{"string_content"}, // keeps ${expr} interpolation intact
+		IdentifierTypes: []string{"identifier"},
+		NamespaceTypes:  []string{"groovy_package", "groovy_import"},
+		ClassTypes:      []string{"class_definition"},
+		CommentTypes:    []string{"comment"},
+	},
+	"ruby": {
+		Name:            "ruby",
+		Extensions:      []string{".rb", ".rake", ".gemspec", ".ru"},
+		Filenames:       []string{"Gemfile", "Rakefile", "Vagrantfile", "Brewfile", "Podfile"},
+		Language:        forest.GetLanguage("ruby"),
+		StringTypes:     []string{"string_content", "heredoc_content"}, // keeps #{expr} interpolation intact
+		IdentifierTypes: []string{"identifier", "constant", "simple_symbol", "hash_key_symbol", "delimited_symbol"},
+		NamespaceTypes:  []string{"module.name"},
+		ClassTypes:      []string{"class", "singleton_class"},
+		CommentTypes:    []string{"comment"},
+	},
+	"php": {
+		Name:            "php",
+		Extensions:      []string{".php", ".phtml"},
+		Language:        forest.GetLanguage("php"),
+		StringTypes:     []string{"string_content"},
+		IdentifierTypes: []string{"name"},
+		NamespaceTypes:  []string{"namespace_definition", "namespace_use_declaration"},
+		ClassTypes:      []string{"class_declaration", "interface_declaration", "trait_declaration", "enum_declaration"},
+		CommentTypes:    []string{"comment"},
+		// HTML outside of <?php ?> tags
+		Categories: map[NodeCategory][]string{
+			"html": {"text"},
+		},
+	},
+	"lua": {
+		Name:            "lua",
+		Extensions:      []string{".lua"},
+		Language:        forest.GetLanguage("lua"),
+		StringTypes:     []string{"string_content"},
+		IdentifierTypes: []string{"identifier"},
+		CommentTypes:    []string{"comment"},
+	},
+	"elixir": {
+		Name:            "elixir",
+		Extensions:      []string{".ex", ".exs"},
+		Language:        forest.GetLanguage("elixir"),
+		StringTypes:     []string{"quoted_content"}, // strings, sigils and quoted atoms; keeps #{expr} intact
+		IdentifierTypes: []string{"identifier", "atom", "keyword"},
+		NamespaceTypes:  []string{"alias"},
+		CommentTypes:    []string{"comment"},
+	},
+	"proto": {
+		Name:            "proto",
+		Extensions:      []string{".proto"},
+		Language:        forest.GetLanguage("proto"),
+		StringTypes:     []string{"string"},
+		IdentifierTypes: []string{"identifier"},
+		NamespaceTypes:  []string{"package"},
+		ClassTypes:      []string{"message", "enum", "service"},
+		CommentTypes:    []string{"comment"},
+	},
+	"graphql": {
+		Name:            "graphql",
+		Extensions:      []string{".graphql", ".graphqls", ".gql"},
+		Language:        forest.GetLanguage("graphql"),
+		StringTypes:     []string{"string_value"},
+		IdentifierTypes: []string{"name"},
+		ClassTypes: []string{
+			"object_type_definition", "interface_type_definition", "input_object_type_definition",
+			"enum_type_definition", "union_type_definition", "scalar_type_definition",
+		},
+		CommentTypes: []string{"comment"},
+	},
+	"sql": {
+		Name:            "sql",
+		Extensions:      []string{".sql"},
+		Language:        forest.GetLanguage("sql"),
+		StringTypes:     []string{"literal"},
+		IdentifierTypes: []string{"identifier"},
+		NamespaceTypes:  []string{"object_reference.schema", "create_schema.identifier"},
+		CommentTypes:    []string{"comment", "marginalia"},
+	},
+	"html": {
+		Name:         "html",
+		Extensions:   []string{".html", ".htm", ".xhtml"},
+		Language:     forest.GetLanguage("html"),
+		StringTypes:  []string{"text", "attribute_value"},
+		CommentTypes: []string{"comment"},
+		Injections:   elementInjections,
+	},
+	"css": {
+		Name:            "css",
+		Extensions:      []string{".css"},
+		Language:        forest.GetLanguage("css"),
+		StringTypes:     []string{"string_content"},
+		IdentifierTypes: []string{"id_name", "property_name", "arguments.plain_value"},
+		ClassTypes:      []string{"class_selector.class_name"},
+		CommentTypes:    []string{"comment", "js_comment"},
+	},
+	"scss": {
+		Name:            "scss",
+		Extensions:      []string{".scss"},
+		Language:        forest.GetLanguage("scss"),
+		StringTypes:     []string{"string_value"},
+		IdentifierTypes: []string{"id_name", "property_name", "variable_name", "variable_value", "arguments.plain_value"},
+		ClassTypes:      []string{"class_selector.class_name"},
+		CommentTypes:    []string{"comment", "single_line_comment"},
+	},
+	"vue": {
+		Name:         "vue",
+		Extensions:   []string{".vue"},
+		Language:     forest.GetLanguage("vue"),
+		StringTypes:  []string{"text", "attribute_value"},
+		CommentTypes: []string{"comment"},
+		Injections:   elementInjections,
+	},
+	"svelte": {
+		Name:         "svelte",
+		Extensions:   []string{".svelte"},
+		Language:     forest.GetLanguage("svelte"),
+		StringTypes:  []string{"text", "attribute_value"},
+		CommentTypes: []string{"comment"},
+		Injections:   elementInjections,
+	},
+	"properties": {
+		Name:            "properties",
+		Extensions:      []string{".properties"},
+		Language:        forest.GetLanguage("properties"),
+		StringTypes:     []string{"value"},
+		IdentifierTypes: []string{"key"},
+		CommentTypes:    []string{"comment"},
+	},
+	"dotenv": {
+		Name:            "dotenv",
+		Extensions:      []string{".env"},
+		Filenames:       []string{".env", ".env.example", ".env.sample", ".env.local", ".env.development", ".env.production", ".env.test"},
+		Language:        forest.GetLanguage("dotenv"),
+		StringTypes:     []string{"value"},
+		IdentifierTypes: []string{"identifier"},
+		CommentTypes:    []string{"comment"},
+	},
+	"hocon": {
+		Name:            "hocon",
+		Extensions:      []string{".hocon"},
+		Filenames:       []string{"application.conf", "reference.conf"},
+		Language:        forest.GetLanguage("hocon"),
+		StringTypes:     []string{"string", "multiline_string", "unquoted_string", "number"},
+		IdentifierTypes: []string{"path"},
+		CommentTypes:    []string{"comment"},
+	},
+	"sln": {
+		Name:            "sln",
+		Extensions:      []string{".sln"},
+		Language:        nil, // line based format, transformed as plaintext
+		StringTypes:     []string{},
+		IdentifierTypes: []string{},
+		CommentTypes:    []string{},
+	},
 	"plaintext": {
 		Name:            "plaintext",
-		Extensions:      []string{".txt", ".sln", ".env.example"},
-		Filenames:       []string{"LICENSE", "LICENCE", "NOTICE", "AUTHORS", "CODEOWNERS", ".env.example"},
+		Extensions:      []string{".txt"},
+		Filenames:       []string{"LICENSE", "LICENCE", "NOTICE", "AUTHORS", "CODEOWNERS"},
 		Language:        nil, // No AST parsing, use simple string replacement
 		StringTypes:     []string{},
 		IdentifierTypes: []string{},
@@ -153,36 +459,108 @@ var Languages = map[string]*LanguageConfig{
 	},
 }
 
-// maps file extensions to language names
-var extensionToLanguage = make(map[string]string)
+// elementInjections hands <script> and <style> blocks of HTML-like documents
+// to their own grammars, honouring a lang attribute
+var elementInjections = []Injection{
+	{NodeType: "script_element", Language: "javascript", LanguageAttribute: "lang", ContentNode: "raw_text"},
+	{NodeType: "style_element", Language: "css", LanguageAttribute: "lang", ContentNode: "raw_text"},
+}
 
-var filenameToLanguage = make(map[string]string)
+// Table is a set of languages along with the files each one applies to
+type Table struct {
+	Languages map[string]*LanguageConfig
+	// maps file extensions and file names to language names
+	extensions map[string]string
+	filenames  map[string]string
+}
 
-func init() {
-	for name, config := range Languages {
-		for _, ext := range config.Extensions {
-			extensionToLanguage[ext] = name
-		}
-		for _, filename := range config.Filenames {
-			filenameToLanguage[filename] = name
+// builtin indexes the files of the built-in languages
+var builtin = newTable(Languages)
+
+func newTable(langs map[string]*LanguageConfig) *Table {
+	t := &Table{
+		Languages:  langs,
+		extensions: make(map[string]string),
+		filenames:  make(map[string]string),
+	}
+	for _, name := range slices.Sorted(maps.Keys(langs)) {
+		t.mapFiles(langs[name])
+	}
+	return t
+}
+
+func (t *Table) mapFiles(config *LanguageConfig) {
+	for _, ext := range config.Extensions {
+		t.extensions[ext] = config.Name
+	}
+	for _, filename := range config.Filenames {
+		t.filenames[filename] = config.Name
+	}
+}
+
+// Register adds a language to the table, replacing any language with the
+// same name, and maps its extensions and filenames to it.
+func (t *Table) Register(config *LanguageConfig) {
+	for i, ext := range config.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
 		}
+		config.Extensions[i] = strings.ToLower(ext)
 	}
+	t.Languages[config.Name] = config
+	t.mapFiles(config)
 }
 
-func GetLanguageForExtension(ext string) *LanguageConfig {
-	if name, ok := extensionToLanguage[ext]; ok {
-		return Languages[name]
+// Grammar returns the go-sitter-forest grammar with the given name
+func Grammar(name string) (*sitter.Language, error) {
+	if !forest.SupportedLanguage(name) {
+		return nil, fmt.Errorf("unknown grammar %q", name)
+	}
+	return forest.GetLanguage(name), nil
+}
+
+// Builtin returns a copy of the built-in languages that a spec can adjust
+// without affecting any other spec
+func Builtin() *Table {
+	langs := make(map[string]*LanguageConfig, len(Languages))
+	for name, config := range Languages {
+		langs[name] = config.Clone()
+	}
+	return newTable(langs)
+}
+
+// Clone returns a copy of the config whose files and categories can be
+// changed independently
+func (lc *LanguageConfig) Clone() *LanguageConfig {
+	clone := *lc
+	clone.Extensions = slices.Clone(lc.Extensions)
+	clone.Filenames = slices.Clone(lc.Filenames)
+	clone.Categories = maps.Clone(lc.Categories)
+	return &clone
+}
+
+func (t *Table) GetLanguageForExtension(ext string) *LanguageConfig {
+	if name, ok := t.extensions[ext]; ok {
+		return t.Languages[name]
 	}
 	return nil
 }
 
-func GetLanguageForFile(path string) *LanguageConfig {
+func (t *Table) GetLanguageForFile(path string) *LanguageConfig {
 	filename := filepath.Base(path)
-	if name, ok := filenameToLanguage[filename]; ok {
-		return Languages[name]
+	if name, ok := t.filenames[filename]; ok {
+		return t.Languages[name]
 	}
 	ext := strings.ToLower(filepath.Ext(path))
-	return GetLanguageForExtension(ext)
+	return t.GetLanguageForExtension(ext)
+}
+
+func GetLanguageForExtension(ext string) *LanguageConfig {
+	return builtin.GetLanguageForExtension(ext)
+}
+
+func GetLanguageForFile(path string) *LanguageConfig {
+	return builtin.GetLanguageForFile(path)
 }
 
 func (lc *LanguageConfig) GetNodeCategory(nodeType string) NodeCategory {
@@ -202,20 +580,149 @@ func (lc *LanguageConfig) GetNodeCategory(nodeType string) NodeCategory {
 	if slices.Contains(lc.CommentTypes, nodeType) {
 		return CategoryComment
 	}
+	for _, cat := range slices.Sorted(maps.Keys(lc.Categories)) {
+		if slices.Contains(lc.Categories[cat], nodeType) {
+			return cat
+		}
+	}
 	return ""
 }
 
+// NodeTypesFor returns the node types mapped to a category
+func (lc *LanguageConfig) NodeTypesFor(cat NodeCategory) []string {
+	switch cat {
+	case CategoryString:
+		return lc.StringTypes
+	case CategoryIdentifier:
+		return lc.IdentifierTypes
+	case CategoryNamespace:
+		return lc.NamespaceTypes
+	case CategoryClass:
+		return lc.ClassTypes
+	case CategoryComment:
+		return lc.CommentTypes
+	}
+	return lc.Categories[cat]
+}
+
+// OverrideCategory adds and removes node types from a category, creating the
+// category when it is not a built-in one.
+func (lc *LanguageConfig) OverrideCategory(cat NodeCategory, add, remove []string) {
+	nodeTypes := slices.Clone(lc.NodeTypesFor(cat))
+	for _, nt := range add {
+		if !slices.Contains(nodeTypes, nt) {
+			nodeTypes = append(nodeTypes, nt)
+		}
+	}
+	nodeTypes = slices.DeleteFunc(nodeTypes, func(nt string) bool {
+		return slices.Contains(remove, nt)
+	})
+
+	switch cat {
+	case CategoryString:
+		lc.StringTypes = nodeTypes
+	case CategoryIdentifier:
+		lc.IdentifierTypes = nodeTypes
+	case CategoryNamespace:
+		lc.NamespaceTypes = nodeTypes
+	case CategoryClass:
+		lc.ClassTypes = nodeTypes
+	case CategoryComment:
+		lc.CommentTypes = nodeTypes
+	default:
+		if lc.Categories == nil {
+			lc.Categories = make(map[NodeCategory][]string)
+		}
+		lc.Categories[cat] = nodeTypes
+	}
+}
+
+// IsCategory reports whether cat is a built-in category or one defined for
+// any language of the table.
+func (t *Table) IsCategory(cat NodeCategory) bool {
+	switch cat {
+	case CategoryString, CategoryIdentifier, CategoryNamespace, CategoryClass, CategoryComment, CategoryAny:
+		return true
+	}
+	for _, lc := range t.Languages {
+		if _, ok := lc.Categories[cat]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // MatchesCategory checks if a node type matches given categories
 func (lc *LanguageConfig) MatchesCategory(nodeType string, categories []NodeCategory) bool {
-	nodeCategory := lc.GetNodeCategory(nodeType)
-	if nodeCategory == "" {
-		return false
+	for _, cat := range categories {
+		if lang, rawType, ok := ParseRawNodeType(cat); ok {
+			if lang == lc.Name && rawType == nodeType {
+				return true
+			}
+			continue
+		}
+		if cat == CategoryAny {
+			if lc.GetNodeCategory(nodeType) != "" {
+				return true
+			}
+			continue
+		}
+		if slices.Contains(lc.NodeTypesFor(cat), nodeType) {
+			return true
+		}
 	}
+	return false
+}
 
-	for _, cat := range categories {
-		if cat == CategoryAny || cat == nodeCategory {
+// ParseRawNodeType splits a language qualified grammar node type such as
+// "go:import_spec" into its language and node type.
+func ParseRawNodeType(cat NodeCategory) (string, string, bool) {
+	lang, nodeType, ok := strings.Cut(string(cat), ":")
+	if !ok || lang == "" || nodeType == "" {
+		return "", "", false
+	}
+	return lang, nodeType, true
+}
+
+// HasNodeType reports whether the grammar defines a node type. Qualified
+// names such as "env_pair.value" are checked as a parent node type followed
+// by one of its field names or a child node type.
+func (lc *LanguageConfig) HasNodeType(name string) bool {
+	nodeTypes := lc.NodeTypes()
+	parent, child, qualified := strings.Cut(name, ".")
+	if !qualified {
+		return slices.Contains(nodeTypes, name)
+	}
+	if !slices.Contains(nodeTypes, parent) {
+		return false
+	}
+	if slices.Contains(nodeTypes, child) {
+		return true
+	}
+	for i := 1; i <= int(lc.Language.FieldCount()); i++ {
+		if lc.Language.FieldName(i) == child {
 			return true
 		}
 	}
 	return false
 }
+
+// NodeTypes returns the named node types defined by the language's grammar
+func (lc *LanguageConfig) NodeTypes() []string {
+	if lc.Language == nil {
+		return nil
+	}
+	var nodeTypes []string
+	for i := uint32(0); i < lc.Language.SymbolCount(); i++ {
+		sym := sitter.Symbol(i)
+		if lc.Language.SymbolType(sym) != sitter.SymbolTypeRegular {
+			continue
+		}
+		name := lc.Language.SymbolName(sym)
+		if !slices.Contains(nodeTypes, name) {
+			nodeTypes = append(nodeTypes, name)
+		}
+	}
+	slices.Sort(nodeTypes)
+	return nodeTypes
+}
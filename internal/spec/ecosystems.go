@@ -0,0 +1,906 @@
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+)
+
+// Ecosystems configures package-aware renaming. Each ecosystem declares a
+// package name once as a variable and rewrites it consistently in manifests,
+// imports and other files.
+type Ecosystems struct {
+	Go     *GoEcosystem     `yaml:"go"`
+	Java   *JavaEcosystem   `yaml:"java"`
+	Python *PythonEcosystem `yaml:"python"`
+	Dotnet *DotnetEcosystem `yaml:"dotnet"`
+	Node   *NodeEcosystem   `yaml:"node"`
+	Rust   *RustEcosystem   `yaml:"rust"`
+}
+
+// GoEcosystem rewrites a Go module path in go.mod, go.work, import paths and
+// package paths embedded in other files, such as -X ldflags.
+type GoEcosystem struct {
+	// Module is the module path to rewrite, read from go.mod when empty
+	Module string `yaml:"module"`
+	// Variable holds the module path in the template, go_module by default
+	Variable string `yaml:"variable"`
+}
+
+// JavaEcosystem renames a Java or Kotlin base package in package and import
+// declarations and relocates the package directories of every source set.
+type JavaEcosystem struct {
+	// Package is the dotted base package, read from the main sources when empty
+	Package string `yaml:"package"`
+	// Variable holds the package in the template, java_package by default.
+	// A computed <variable>_path variable holds it as nested folders.
+	Variable string `yaml:"variable"`
+}
+
+// PythonEcosystem renames a Python project, keeping its distribution name
+// (acme-widget) apart from its import name (acme_widget).
+type PythonEcosystem struct {
+	// Distribution is the name published to package indexes, read from
+	// pyproject.toml or setup.cfg when empty
+	Distribution string `yaml:"distribution"`
+	// Package is the import name, the distribution in snake_case by default
+	Package string `yaml:"package"`
+	// DistributionVariable holds the distribution name in the template,
+	// python_distribution by default
+	DistributionVariable string `yaml:"distribution_variable"`
+	// PackageVariable holds the import name in the template, python_package
+	// by default. It is computed from the distribution unless Package differs
+	// from its default.
+	PackageVariable string `yaml:"package_variable"`
+}
+
+// DotnetEcosystem renames a .NET solution: its .sln project entries, project
+// and assembly names, namespaces, project references and the folders and
+// files named after it.
+type DotnetEcosystem struct {
+	// Name is the solution name and root namespace, taken from the .sln file
+	// name when empty
+	Name string `yaml:"name"`
+	// Variable holds the name in the template, solution_name by default
+	Variable string `yaml:"variable"`
+	// TemplateGUIDs replaces project GUIDs with computed variables, so each
+	// generated solution gets fresh ones
+	TemplateGUIDs bool `yaml:"template_guids"`
+}
+
+// NodeEcosystem renames an npm package, with its scope and name as separate
+// variables, in manifests, import specifiers and lockfiles. Workspace packages
+// sharing the scope get the new scope too.
+type NodeEcosystem struct {
+	// Package is the package name, such as @acme/widget, read from the root
+	// package.json when empty
+	Package string `yaml:"package"`
+	// ScopeVariable holds the scope without its @, npm_scope by default
+	ScopeVariable string `yaml:"scope_variable"`
+	// NameVariable holds the unscoped name, npm_name by default
+	NameVariable string `yaml:"name_variable"`
+	// Lockfiles is how lockfiles are handled: template (the default),
+	// exclude, or regenerate, which excludes them and adds an install task
+	Lockfiles string `yaml:"lockfiles"`
+}
+
+// RustEcosystem renames a crate, dashed in Cargo manifests (acme-widget) and
+// snake_case in Rust paths (acme_widget). Workspace members named after the
+// crate, such as acme-widget-core, are renamed with it.
+type RustEcosystem struct {
+	// Crate is the package name, read from the root Cargo.toml when empty
+	Crate string `yaml:"crate"`
+	// Variable holds the package name in the template, crate_name by default.
+	// A computed <variable>_ident variable holds its snake_case form.
+	Variable string `yaml:"variable"`
+	// Lockfile is how Cargo.lock is handled: exclude (the default), template,
+	// or regenerate, which excludes it and adds a cargo task
+	Lockfile string `yaml:"lockfile"`
+}
+
+// Lockfile policies of the Node and Rust modes
+const (
+	LockfilesTemplate   = "template"
+	LockfilesExclude    = "exclude"
+	LockfilesRegenerate = "regenerate"
+)
+
+// nodeLockfiles maps lockfiles to the command regenerating them
+var nodeLockfiles = map[string]string{
+	"package-lock.json": "npm install",
+	"pnpm-lock.yaml":    "pnpm install",
+	"yarn.lock":         "yarn install",
+}
+
+// guidExpr renders a fresh braced, upper case GUID in Copier, seeded with
+// the name of the variable holding it
+const guidExpr = "{{ '{' ~ (('%s-' ~ (range(100000) | random) ~ '-' ~ (range(100000) | random)) | to_uuid | upper) ~ '}' }}"
+
+// cargoLockfiles maps Cargo.lock to the command regenerating it
+var cargoLockfiles = map[string]string{
+	"Cargo.lock": "cargo generate-lockfile",
+}
+
+// solutionProject is a Project entry of a .sln file
+type solutionProject struct {
+	TypeGUID string
+	Name     string
+	Path     string
+	GUID     string
+}
+
+// pathRewrite relocates the directories of a package found below one of the
+// parent directories, given as segment patterns where "**" spans directories
+type pathRewrite struct {
+	parents [][]string
+	dirs    []string
+	to      string
+}
+
+// nameRewrite renames files and folders named after a dotted name, such as
+// Acme.Widget.Api.csproj for Acme.Widget. Only the given paths are renamed,
+// along with the folders leading to them, so other files sharing the name,
+// like a Web project's Web.config, keep theirs.
+type nameRewrite struct {
+	name  string
+	to    string
+	paths [][]string
+}
+
+func (r nameRewrite) apply(segments []string) []string {
+	renamed := slices.Clone(segments)
+	for _, path := range r.paths {
+		dir := path[:len(path)-1]
+		if len(segments) <= len(dir) || !slices.Equal(segments[:len(dir)], dir) {
+			continue
+		}
+		for i := range dir {
+			renamed[i] = r.rename(segments[i])
+		}
+		// other files in a project folder keep their own names
+		if len(segments) == len(path) && segments[len(dir)] == path[len(dir)] {
+			renamed[len(dir)] = r.rename(segments[len(dir)])
+		}
+	}
+	return renamed
+}
+
+func (r nameRewrite) rename(segment string) string {
+	if segment == r.name || strings.HasPrefix(segment, r.name+".") {
+		return r.to + segment[len(r.name):]
+	}
+	return segment
+}
+
+// javaSourceRoots are the directories below src/<set>/ holding packages
+var javaSourceRoots = []string{"java", "kotlin", "groovy", "scala", "resources"}
+
+// pythonLanguages are the languages the Python mode rewrites
+var pythonLanguages = []string{"python", "toml", "ini"}
+
+// nodeLanguages are the languages the Node mode rewrites outside of
+// lockfiles
+var nodeLanguages = []string{"json", "javascript", "typescript"}
+
+// nodeLockfileLanguages are the languages of the Node lockfiles, yarn.lock
+// being read as plaintext
+var nodeLockfileLanguages = []string{"json", "yaml", "plaintext"}
+
+// rustLanguages are the languages the Rust mode rewrites
+var rustLanguages = []string{"rust", "toml"}
+
+// dotnetLanguages are the languages the .NET mode rewrites: solutions,
+// project files and C#
+var dotnetLanguages = []string{"sln", "xml", "csharp"}
+
+// solutionFolderType is the project type GUID of .sln solution folders
+const solutionFolderType = "2150E333-8FDC-42A3-9474-1A3956D46DE8"
+
+var (
+	goModuleRx    = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+	javaPackageRx = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)`)
+	slnProjectRx  = regexp.MustCompile(`(?m)^Project\("\{([^}]+)\}"\)\s*=\s*"([^"]*)",\s*"([^"]*)",\s*"\{([^}]+)\}"`)
+	sectionRx     = regexp.MustCompile(`^\s*\[+([^\]]+)\]+`)
+	keyValueRx    = regexp.MustCompile(`^\s*([\w.-]+)\s*=\s*(.+?)\s*$`)
+	nameRx        = regexp.MustCompile(`^["']?([^"'\s]+)`)
+)
+
+// ecosystemNodeTypes are the categories ecosystem renames apply to
+var ecosystemNodeTypes = []languages.NodeCategory{
+	languages.CategoryString,
+	languages.CategoryNamespace,
+	languages.CategoryComment,
+}
+
+// ApplyEcosystems resolves the configured ecosystems against the source
+// directory, declaring their variables and excludes and the transforms they
+// contribute to BuildTransforms.
+func (s *Spec) ApplyEcosystems(sourceDir string) error {
+	if s.Ecosystems.Go != nil {
+		if err := s.applyGo(sourceDir, s.Ecosystems.Go); err != nil {
+			return fmt.Errorf("ecosystems: go: %w", err)
+		}
+	}
+	if s.Ecosystems.Java != nil {
+		if err := s.applyJava(sourceDir, s.Ecosystems.Java); err != nil {
+			return fmt.Errorf("ecosystems: java: %w", err)
+		}
+	}
+	if s.Ecosystems.Python != nil {
+		if err := s.applyPython(sourceDir, s.Ecosystems.Python); err != nil {
+			return fmt.Errorf("ecosystems: python: %w", err)
+		}
+	}
+	if s.Ecosystems.Dotnet != nil {
+		if err := s.applyDotnet(sourceDir, s.Ecosystems.Dotnet); err != nil {
+			return fmt.Errorf("ecosystems: dotnet: %w", err)
+		}
+	}
+	if s.Ecosystems.Node != nil {
+		if err := s.applyNode(sourceDir, s.Ecosystems.Node); err != nil {
+			return fmt.Errorf("ecosystems: node: %w", err)
+		}
+	}
+	if s.Ecosystems.Rust != nil {
+		if err := s.applyRust(sourceDir, s.Ecosystems.Rust); err != nil {
+			return fmt.Errorf("ecosystems: rust: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) applyGo(sourceDir string, eco *GoEcosystem) error {
+	module := eco.Module
+	if module == "" {
+		var err error
+		module, err = ReadGoModule(filepath.Join(sourceDir, "go.mod"))
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.New("no module given and no go.mod found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	variable := eco.Variable
+	if variable == "" {
+		variable = "go_module"
+	}
+	s.declareVariable(variable, "Go module path", module)
+	s.exclude("go.sum")
+
+	s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+		Match:         module,
+		Replace:       fmt.Sprintf("{{ %s }}", variable),
+		NodeTypes:     ecosystemNodeTypes,
+		CaseSensitive: true,
+		WholePath:     true,
+	})
+	return nil
+}
+
+func (s *Spec) applyJava(sourceDir string, eco *JavaEcosystem) error {
+	pkg := eco.Package
+	if pkg == "" {
+		var err error
+		if pkg, err = basePackage(sourceDir); err != nil {
+			return err
+		}
+	}
+
+	variable := eco.Variable
+	if variable == "" {
+		variable = "java_package"
+	}
+	pathVariable := variable + "_path"
+	s.declareVariable(variable, "Java base package", pkg)
+	s.declareComputed(pathVariable, fmt.Sprintf("{{ %s | replace('.', '/') }}", variable))
+
+	s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+		Match:         pkg,
+		Replace:       fmt.Sprintf("{{ %s }}", variable),
+		NodeTypes:     append(slices.Clone(ecosystemNodeTypes), "java:import_declaration"),
+		CaseSensitive: true,
+		WholePath:     true,
+	})
+	var parents [][]string
+	for _, root := range javaSourceRoots {
+		parents = append(parents, []string{"**", "src", "*", root})
+	}
+	s.pathRewrites = append(s.pathRewrites, pathRewrite{
+		parents: parents,
+		dirs:    strings.Split(pkg, "."),
+		to:      fmt.Sprintf("{{ %s }}", pathVariable),
+	})
+	return nil
+}
+
+func (s *Spec) applyPython(sourceDir string, eco *PythonEcosystem) error {
+	dist := eco.Distribution
+	if dist == "" {
+		dist = distributionName(sourceDir)
+		if dist == "" {
+			return errors.New("no distribution given and no name found in pyproject.toml or setup.cfg")
+		}
+	}
+	derived := strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(dist))
+	pkg := eco.Package
+	if pkg == "" {
+		pkg = derived
+	}
+
+	distVariable := eco.DistributionVariable
+	if distVariable == "" {
+		distVariable = "python_distribution"
+	}
+	pkgVariable := eco.PackageVariable
+	if pkgVariable == "" {
+		pkgVariable = "python_package"
+	}
+	s.declareVariable(distVariable, "Python distribution name", dist)
+	if pkg == derived {
+		s.declareComputed(pkgVariable, fmt.Sprintf("{{ %s | lower | replace('-', '_') | replace('.', '_') }}", distVariable))
+	} else {
+		s.declareVariable(pkgVariable, "Python import package", pkg)
+	}
+
+	// the import name goes first: where both names are the same, package
+	// indexes treat the snake_case form as the same distribution
+	s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+		Match:         pkg,
+		Replace:       fmt.Sprintf("{{ %s }}", pkgVariable),
+		NodeTypes:     append(slices.Clone(ecosystemNodeTypes), languages.CategoryIdentifier),
+		CaseSensitive: true,
+		Languages:     pythonLanguages,
+		WholePath:     true,
+	})
+	if dist != pkg {
+		s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+			Match:         dist,
+			Replace:       fmt.Sprintf("{{ %s }}", distVariable),
+			NodeTypes:     append(slices.Clone(ecosystemNodeTypes), "toml:bare_key"),
+			CaseSensitive: true,
+			Languages:     pythonLanguages,
+			WholePath:     true,
+		})
+	}
+	s.pathRewrites = append(s.pathRewrites, pathRewrite{
+		parents: [][]string{{}, {"src"}},
+		dirs:    []string{pkg},
+		to:      fmt.Sprintf("{{ %s }}", pkgVariable),
+	})
+	return nil
+}
+
+// distributionName reads the project name from pyproject.toml, either PEP 621
+// or Poetry metadata, falling back to setup.cfg.
+func distributionName(sourceDir string) string {
+	if name := sectionName(filepath.Join(sourceDir, "pyproject.toml"), "project", "tool.poetry"); name != "" {
+		return name
+	}
+	return sectionName(filepath.Join(sourceDir, "setup.cfg"), "metadata")
+}
+
+// sectionName returns the name key of the first of the given sections of an
+// INI-like file, such as TOML or setup.cfg
+func sectionName(path string, sections ...string) string {
+	found := ReadSections(path)
+	for _, section := range sections {
+		if m := nameRx.FindStringSubmatch(found[section]["name"]); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// ReadGoModule returns the module path declared by a go.mod file
+func ReadGoModule(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading go.mod: %w", err)
+	}
+	m := goModuleRx.FindSubmatch(data)
+	if m == nil {
+		return "", errors.New("go.mod has no module directive")
+	}
+	return string(m[1]), nil
+}
+
+// ReadSections returns the single line key values of each section of an
+// INI-like file, such as TOML, setup.cfg or a git config. Values are kept as
+// written, quotes included, and the first of a repeated key wins.
+func ReadSections(path string) map[string]map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sections := make(map[string]map[string]string)
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := sectionRx.FindStringSubmatch(line); m != nil && !strings.Contains(line, "=") {
+			section = strings.TrimSpace(m[1])
+			continue
+		}
+		if m := keyValueRx.FindStringSubmatch(line); m != nil {
+			if sections[section] == nil {
+				sections[section] = make(map[string]string)
+			}
+			if _, ok := sections[section][m[1]]; !ok {
+				sections[section][m[1]] = m[2]
+			}
+		}
+	}
+	return sections
+}
+
+// basePackage returns the package of the Java and Kotlin files of main
+// source sets that most of them are in or below, preferring the shorter one.
+func basePackage(sourceDir string) (string, error) {
+	var packages []string
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != sourceDir && (strings.HasPrefix(name, ".") || name == "build" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".java" && ext != ".kt" || !strings.Contains(filepath.ToSlash(path), "/src/main/") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if m := javaPackageRx.FindSubmatch(data); m != nil {
+			packages = append(packages, string(m[1]))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("scanning sources: %w", err)
+	}
+
+	var base string
+	bestCount := 0
+	for _, candidate := range packages {
+		count := 0
+		for _, pkg := range packages {
+			if pkg == candidate || strings.HasPrefix(pkg, candidate+".") {
+				count++
+			}
+		}
+		if count > bestCount || count == bestCount && len(candidate) < len(base) {
+			base, bestCount = candidate, count
+		}
+	}
+	if base == "" {
+		return "", errors.New("no package given and none found in src/main")
+	}
+	return base, nil
+}
+
+func (s *Spec) applyDotnet(sourceDir string, eco *DotnetEcosystem) error {
+	solutions, err := filepath.Glob(filepath.Join(sourceDir, "*.sln"))
+	if err != nil {
+		return err
+	}
+	name := eco.Name
+	if name == "" {
+		if len(solutions) != 1 {
+			return fmt.Errorf("no name given and %d .sln files found", len(solutions))
+		}
+		name = strings.TrimSuffix(filepath.Base(solutions[0]), ".sln")
+	}
+
+	variable := eco.Variable
+	if variable == "" {
+		variable = "solution_name"
+	}
+	s.declareVariable(variable, "Solution name and root namespace", name)
+	replace := fmt.Sprintf("{{ %s }}", variable)
+
+	s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+		Match:         name,
+		Replace:       replace,
+		NodeTypes:     append(slices.Clone(ecosystemNodeTypes), "csharp:using_directive"),
+		CaseSensitive: true,
+		Languages:     dotnetLanguages,
+		WholePath:     true,
+	})
+
+	// only the solution and the projects named after it are renamed
+	rewrite := nameRewrite{name: name, to: replace}
+	var projects []solutionProject
+	for _, sln := range solutions {
+		data, err := os.ReadFile(sln)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", filepath.Base(sln), err)
+		}
+		rewrite.paths = append(rewrite.paths, []string{filepath.Base(sln)})
+		for _, p := range parseSolution(data) {
+			projects = append(projects, p)
+			if p.TypeGUID != solutionFolderType && (p.Name == name || strings.HasPrefix(p.Name, name+".")) {
+				rewrite.paths = append(rewrite.paths, strings.Split(strings.ReplaceAll(p.Path, `\`, "/"), "/"))
+			}
+		}
+	}
+	s.nameRewrites = append(s.nameRewrites, rewrite)
+
+	if !eco.TemplateGUIDs {
+		return nil
+	}
+	for i, p := range projects {
+		guidVariable := fmt.Sprintf("%s_guid_%d", variable, i+1)
+		// braces are part of the value, as "{{{" would not be valid Jinja.
+		// Copier's sandbox caps range() at 100000 items, so the seed is two
+		// draws, with the variable name telling projects apart.
+		s.declareComputed(guidVariable, fmt.Sprintf(guidExpr, guidVariable))
+		s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+			Match:         "{" + p.GUID + "}",
+			Replace:       fmt.Sprintf("{{ %s }}", guidVariable),
+			NodeTypes:     ecosystemNodeTypes,
+			CaseSensitive: true,
+			Languages:     dotnetLanguages,
+			WholePath:     true,
+		})
+	}
+	return nil
+}
+
+func (s *Spec) applyNode(sourceDir string, eco *NodeEcosystem) error {
+	pkg := eco.Package
+	if pkg == "" {
+		pkg = packageName(filepath.Join(sourceDir, "package.json"))
+		if pkg == "" {
+			return errors.New("no package given and no name found in package.json")
+		}
+	}
+
+	scopeVariable := eco.ScopeVariable
+	if scopeVariable == "" {
+		scopeVariable = "npm_scope"
+	}
+	nameVariable := eco.NameVariable
+	if nameVariable == "" {
+		nameVariable = "npm_name"
+	}
+
+	scope, name, scoped := strings.Cut(strings.TrimPrefix(pkg, "@"), "/")
+	if !scoped || !strings.HasPrefix(pkg, "@") {
+		scope, name = "", pkg
+	}
+	s.declareVariable(nameVariable, "npm package name, without its scope", name)
+	replace := fmt.Sprintf("{{ %s }}", nameVariable)
+	if scope != "" {
+		s.declareVariable(scopeVariable, "npm scope, without the @", scope)
+		replace = fmt.Sprintf("@{{ %s }}/%s", scopeVariable, replace)
+	}
+
+	renames := map[string]string{pkg: replace}
+	if scope != "" {
+		// other workspace packages keep their name under the new scope
+		for _, workspacePkg := range workspacePackages(sourceDir) {
+			if rest, ok := strings.CutPrefix(workspacePkg, "@"+scope+"/"); ok && workspacePkg != pkg {
+				renames[workspacePkg] = fmt.Sprintf("@{{ %s }}/%s", scopeVariable, rest)
+			}
+		}
+	}
+	lockfiles := slices.Sorted(maps.Keys(nodeLockfiles))
+	for _, match := range slices.Sorted(maps.Keys(renames)) {
+		s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+			Match:         match,
+			Replace:       renames[match],
+			NodeTypes:     ecosystemNodeTypes,
+			CaseSensitive: true,
+			ExcludePaths:  lockfiles,
+			Languages:     nodeLanguages,
+			WholePath:     true,
+		})
+
+		// lockfiles also name installed copies of the packages, as
+		// node_modules entries, registry URLs and versioned keys; only
+		// standalone names and workspace links move with the rename
+		lockfileMatches := map[string]string{
+			match:                renames[match],
+			match + "@workspace": renames[match] + "@workspace",
+		}
+		if match != pkg {
+			// npm links workspace members into node_modules; the root
+			// package is never linked there
+			lockfileMatches["node_modules/"+match] = "node_modules/" + renames[match]
+		}
+		for _, lockfileMatch := range slices.Sorted(maps.Keys(lockfileMatches)) {
+			s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+				Match:         lockfileMatch,
+				Replace:       lockfileMatches[lockfileMatch],
+				NodeTypes:     ecosystemNodeTypes,
+				CaseSensitive: true,
+				Paths:         lockfiles,
+				Languages:     nodeLockfileLanguages,
+				WholePath:     true,
+				Standalone:    true,
+			})
+		}
+	}
+
+	policy := eco.Lockfiles
+	if policy == "" {
+		policy = LockfilesTemplate
+	}
+	if policy == LockfilesTemplate {
+		// yarn.lock has no grammar of its own
+		if _, ok := s.LanguageOverrides["yarn.lock"]; !ok {
+			if s.LanguageOverrides == nil {
+				s.LanguageOverrides = make(map[string]string)
+			}
+			s.LanguageOverrides["yarn.lock"] = "plaintext"
+		}
+	}
+	return s.applyLockfiles(sourceDir, policy, nodeLockfiles, "package-lock.json")
+}
+
+func (s *Spec) applyRust(sourceDir string, eco *RustEcosystem) error {
+	crate := eco.Crate
+	if crate == "" {
+		crate = sectionName(filepath.Join(sourceDir, "Cargo.toml"), "package")
+		if crate == "" {
+			return errors.New("no crate given and no package name found in Cargo.toml")
+		}
+	}
+	ident := strings.ReplaceAll(crate, "-", "_")
+
+	variable := eco.Variable
+	if variable == "" {
+		variable = "crate_name"
+	}
+	identVariable := variable + "_ident"
+	s.declareVariable(variable, "Crate name", crate)
+	s.declareComputed(identVariable, fmt.Sprintf("{{ %s | replace('-', '_') }}", variable))
+
+	// members such as acme-widget-core keep their suffix
+	suffixes := []string{""}
+	for _, member := range cargoPackages(sourceDir) {
+		if suffix, ok := strings.CutPrefix(member.name, crate+"-"); ok {
+			suffixes = append(suffixes, "-"+suffix)
+			dir := filepath.ToSlash(member.dir)
+			if path.Base(dir) == member.name {
+				var parent []string
+				if path.Dir(dir) != "." {
+					parent = strings.Split(path.Dir(dir), "/")
+				}
+				s.pathRewrites = append(s.pathRewrites, pathRewrite{
+					parents: [][]string{parent},
+					dirs:    []string{member.name},
+					to:      fmt.Sprintf("{{ %s }}-%s", variable, suffix),
+				})
+			}
+		}
+	}
+	slices.Sort(suffixes)
+	// the crate's own name goes last, so it doesn't claim nodes naming a member
+	slices.Reverse(suffixes)
+
+	for _, suffix := range suffixes {
+		identSuffix := strings.ReplaceAll(suffix, "-", "_")
+		s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+			Match:         ident + identSuffix,
+			Replace:       fmt.Sprintf("{{ %s }}%s", identVariable, identSuffix),
+			NodeTypes:     append(slices.Clone(ecosystemNodeTypes), "rust:extern_crate_declaration", "rust:scoped_identifier.path", "toml:bare_key"),
+			CaseSensitive: true,
+			Languages:     rustLanguages,
+			WholePath:     true,
+		})
+		if crate != ident {
+			s.ecosystemTransforms = append(s.ecosystemTransforms, Transform{
+				Match:         crate + suffix,
+				Replace:       fmt.Sprintf("{{ %s }}%s", variable, suffix),
+				NodeTypes:     append(slices.Clone(ecosystemNodeTypes), "toml:bare_key"),
+				CaseSensitive: true,
+				Languages:     rustLanguages,
+				WholePath:     true,
+			})
+		}
+	}
+
+	policy := eco.Lockfile
+	if policy == "" {
+		policy = LockfilesExclude
+	}
+	return s.applyLockfiles(sourceDir, policy, cargoLockfiles, "Cargo.lock")
+}
+
+// applyLockfiles applies a lockfile policy. lockfiles maps each lockfile to
+// the command regenerating it, which becomes a task for the lockfiles found
+// in the source, or for the fallback when there are none.
+func (s *Spec) applyLockfiles(sourceDir, policy string, lockfiles map[string]string, fallback string) error {
+	switch policy {
+	case LockfilesTemplate:
+		return nil
+	case LockfilesExclude, LockfilesRegenerate:
+	default:
+		return fmt.Errorf("unknown lockfile policy %q (want %s, %s or %s)", policy, LockfilesTemplate, LockfilesExclude, LockfilesRegenerate)
+	}
+	var tasks []string
+	for _, lockfile := range slices.Sorted(maps.Keys(lockfiles)) {
+		s.exclude(lockfile)
+		if _, err := os.Stat(filepath.Join(sourceDir, lockfile)); err == nil {
+			tasks = append(tasks, lockfiles[lockfile])
+		}
+	}
+	if policy != LockfilesRegenerate {
+		return nil
+	}
+	if len(tasks) == 0 {
+		tasks = []string{lockfiles[fallback]}
+	}
+	for _, task := range tasks {
+		if !slices.Contains(s.Tasks, task) {
+			s.Tasks = append(s.Tasks, task)
+		}
+	}
+	return nil
+}
+
+// cargoPackage is a package of a Cargo workspace
+type cargoPackage struct {
+	name string
+	dir  string
+}
+
+// cargoPackages returns the packages declared by the Cargo.toml files of the
+// source tree, outside of target directories
+func cargoPackages(sourceDir string) []cargoPackage {
+	var packages []cargoPackage
+	filepath.WalkDir(sourceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name := d.Name(); p != sourceDir && (strings.HasPrefix(name, ".") || name == "target") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "Cargo.toml" {
+			return nil
+		}
+		if name := sectionName(p, "package"); name != "" {
+			dir, err := filepath.Rel(sourceDir, filepath.Dir(p))
+			if err == nil {
+				packages = append(packages, cargoPackage{name: name, dir: dir})
+			}
+		}
+		return nil
+	})
+	return packages
+}
+
+// packageName returns the name field of a package.json file
+func packageName(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var manifest struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return ""
+	}
+	return manifest.Name
+}
+
+// workspacePackages returns the names of the package.json files in the
+// source tree, outside of node_modules
+func workspacePackages(sourceDir string) []string {
+	var names []string
+	filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != sourceDir && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "package.json" {
+			if name := packageName(path); name != "" {
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	return names
+}
+
+// parseSolution returns the Project entries of a .sln file, solution folders
+// included
+func parseSolution(data []byte) []solutionProject {
+	var projects []solutionProject
+	for _, m := range slnProjectRx.FindAllSubmatch(data, -1) {
+		projects = append(projects, solutionProject{
+			TypeGUID: string(m[1]),
+			Name:     string(m[2]),
+			Path:     string(m[3]),
+			GUID:     string(m[4]),
+		})
+	}
+	return projects
+}
+
+// TemplatePath returns where a source file goes in the template, with the
+// package directories of ecosystems replaced by their path variables.
+func (s *Spec) TemplatePath(relPath string) string {
+	if len(s.pathRewrites) == 0 && len(s.nameRewrites) == 0 {
+		return relPath
+	}
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for _, r := range s.pathRewrites {
+		segments = r.apply(segments)
+	}
+	for _, r := range s.nameRewrites {
+		segments = r.apply(segments)
+	}
+	return filepath.FromSlash(strings.Join(segments, "/"))
+}
+
+func (r pathRewrite) apply(segments []string) []string {
+	for i := 0; i+len(r.dirs) < len(segments); i++ {
+		if !slices.Equal(segments[i:i+len(r.dirs)], r.dirs) {
+			continue
+		}
+		for _, parent := range r.parents {
+			if matchSegments(parent, segments[:i]) {
+				return slices.Concat(segments[:i], []string{r.to}, segments[i+len(r.dirs):])
+			}
+		}
+	}
+	return segments
+}
+
+// declareVariable adds a str variable unless the spec already declares it,
+// which lets the spec give it a composite default instead.
+func (s *Spec) declareVariable(name, description string, value any) {
+	if _, ok := s.Variables[name]; ok {
+		return
+	}
+	if s.Variables == nil {
+		s.Variables = make(map[string]*VariableConfig)
+	}
+	s.Variables[name] = &VariableConfig{
+		Type:        "str",
+		Description: description,
+		Default:     value,
+	}
+}
+
+// declareComputed adds a variable derived from others, which is never asked
+func (s *Spec) declareComputed(name, expr string) {
+	s.declareVariable(name, "", expr)
+	if v := s.Variables[name]; v.When == nil {
+		v.When = false
+	}
+}
+
+func (s *Spec) exclude(patterns ...string) {
+	for _, p := range patterns {
+		if !slices.Contains(s.Exclude, p) {
+			s.Exclude = append(s.Exclude, p)
+		}
+	}
+}
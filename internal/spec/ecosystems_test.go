@@ -0,0 +1,319 @@
+package spec_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+	"github.com/tnaucoin/mintmpl/internal/transformer"
+)
+
+// applyEcosystems writes files to a source tree, applies the spec's
+// ecosystems to it and returns the spec with each file as transformed.
+func applyEcosystems(t *testing.T, specYAML string, files map[string]string) (*spec.Spec, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	specPath := filepath.Join(dir, ".mintmpl.yml")
+	if err := os.WriteFile(specPath, []byte(specYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := spec.Load(specPath)
+	if err != nil {
+		t.Fatalf("loading spec: %v", err)
+	}
+	if err := s.ApplyEcosystems(dir); err != nil {
+		t.Fatalf("applying ecosystems: %v", err)
+	}
+	trans := transformer.New(s.BuildTransforms(), nil)
+	trans.SetLanguages(s.LanguageTable())
+	trans.SetLanguageOverrides(s.LanguageOverrides)
+	out := make(map[string]string)
+	for name, content := range files {
+		result, _ := trans.TransformFile(name, []byte(content))
+		out[name] = string(result)
+	}
+	return s, out
+}
+
+func TestGoModulePath(t *testing.T) {
+	s, out := applyEcosystems(t, "ecosystems:\n  go: {}\n", map[string]string{
+		"go.mod": "module github.com/acme/widget\n\ngo 1.22\n",
+		"main.go": "package main\n\n" +
+			"import (\n" +
+			"\t\"github.com/acme/widget/internal/cli\"\n" +
+			"\t\"github.com/acme/widgetry\"\n" +
+			")\n",
+	})
+
+	if v := s.Variables["go_module"]; v == nil || v.Default != "github.com/acme/widget" {
+		t.Errorf("go_module variable = %+v, want the module path as default", v)
+	}
+	if !slices.Contains(s.Exclude, "go.sum") {
+		t.Error("go.sum is not excluded")
+	}
+	if got, want := out["go.mod"], "module {{ go_module }}\n\ngo 1.22\n"; got != want {
+		t.Errorf("go.mod: got %q, want %q", got, want)
+	}
+	// sub packages are renamed, longer module names are not
+	want := "package main\n\n" +
+		"import (\n" +
+		"\t\"{{ go_module }}/internal/cli\"\n" +
+		"\t\"github.com/acme/widgetry\"\n" +
+		")\n"
+	if got := out["main.go"]; got != want {
+		t.Errorf("main.go: got %q, want %q", got, want)
+	}
+}
+
+func TestJavaPackage(t *testing.T) {
+	s, out := applyEcosystems(t, "ecosystems:\n  java: {}\n", map[string]string{
+		"src/main/java/com/acme/widget/App.java": "package com.acme.widget;\n\n" +
+			"import com.acme.widget.util.Strings;\n" +
+			"import com.acme.widgets.Other;\n",
+		"src/main/java/com/acme/widget/util/Strings.java": "package com.acme.widget.util;\n",
+	})
+
+	if v := s.Variables["java_package"]; v == nil || v.Default != "com.acme.widget" {
+		t.Errorf("java_package variable = %+v, want the base package as default", v)
+	}
+	want := "package {{ java_package }};\n\n" +
+		"import {{ java_package }}.util.Strings;\n" +
+		"import com.acme.widgets.Other;\n"
+	if got := out["src/main/java/com/acme/widget/App.java"]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	paths := map[string]string{
+		"src/main/java/com/acme/widget/util/Strings.java":   "src/main/java/{{ java_package_path }}/util/Strings.java",
+		"src/test/kotlin/com/acme/widget/AppTest.kt":        "src/test/kotlin/{{ java_package_path }}/AppTest.kt",
+		"src/main/resources/com/acme/widget/messages.props": "src/main/resources/{{ java_package_path }}/messages.props",
+		"docs/com/acme/widget/README.md":                    "docs/com/acme/widget/README.md",
+	}
+	for path, want := range paths {
+		if got := filepath.ToSlash(s.TemplatePath(filepath.FromSlash(path))); got != want {
+			t.Errorf("TemplatePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestPythonEntryPoints(t *testing.T) {
+	_, out := applyEcosystems(t, "ecosystems:\n  python: {}\n", map[string]string{
+		"pyproject.toml": "[project]\nname = \"acme-widget\"\n\n[project.scripts]\nacme-widget = \"acme_widget.cli:main\"\n",
+	})
+	want := "[project]\nname = \"{{ python_distribution }}\"\n\n[project.scripts]\n{{ python_distribution }} = \"{{ python_package }}.cli:main\"\n"
+	if got := out["pyproject.toml"]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDotnetGUIDs(t *testing.T) {
+	const guid = "8A7C1F4E-0B8D-4C3B-9E61-0C5F1E2D3A4B"
+	s, out := applyEcosystems(t, "ecosystems:\n  dotnet:\n    template_guids: true\n", map[string]string{
+		"Acme.Widget.sln": "Project(\"{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}\") = \"Acme.Widget.Api\", \"src\\Acme.Widget.Api\\Acme.Widget.Api.csproj\", \"{" + guid + "}\"\nEndProject\n",
+	})
+
+	v := s.Variables["solution_name_guid_1"]
+	if v == nil {
+		t.Fatal("no GUID variable declared")
+	}
+	// Copier's sandboxed range() refuses more than 100000 items
+	want := "{{ '{' ~ (('solution_name_guid_1-' ~ (range(100000) | random) ~ '-' ~ (range(100000) | random)) | to_uuid | upper) ~ '}' }}"
+	if v.Default != want {
+		t.Errorf("GUID expression %q, want %q", v.Default, want)
+	}
+	if v.When != false {
+		t.Errorf("GUID variable is asked, when = %v", v.When)
+	}
+	if got := out["Acme.Widget.sln"]; !strings.Contains(got, `"{{ solution_name_guid_1 }}"`) {
+		t.Errorf("GUID not templated in %q", got)
+	}
+}
+
+func TestDotnetRenamesProjects(t *testing.T) {
+	s, out := applyEcosystems(t, "ecosystems:\n  dotnet: {}\n", map[string]string{
+		"Web.sln": "Project(\"{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}\") = \"Web\", \"Web\\Web.csproj\", \"{8A7C1F4E-0B8D-4C3B-9E61-0C5F1E2D3A4B}\"\nEndProject\n",
+		"Web/Web.csproj": "<Project Sdk=\"Microsoft.NET.Sdk.Web\">\n" +
+			"  <PropertyGroup>\n    <RootNamespace>Web</RootNamespace>\n  </PropertyGroup>\n" +
+			"</Project>\n",
+		"Web/Program.cs": "namespace Web;\n",
+		"package.json":   "{\"name\": \"Web\"}\n",
+	})
+
+	paths := map[string]string{
+		"Web.sln":        "{{ solution_name }}.sln",
+		"Web/Web.csproj": "{{ solution_name }}/{{ solution_name }}.csproj",
+		"Web/Program.cs": "{{ solution_name }}/Program.cs",
+		"Web/Web.config": "{{ solution_name }}/Web.config",
+		"docs/Web.md":    "docs/Web.md",
+		"Web.Tests/A.cs": "Web.Tests/A.cs",
+	}
+	for path, want := range paths {
+		if got := filepath.ToSlash(s.TemplatePath(filepath.FromSlash(path))); got != want {
+			t.Errorf("TemplatePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if got, want := out["Web/Program.cs"], "namespace {{ solution_name }};\n"; got != want {
+		t.Errorf("Program.cs: got %q, want %q", got, want)
+	}
+	if got := out["Web/Web.csproj"]; !strings.Contains(got, "<RootNamespace>{{ solution_name }}</RootNamespace>") {
+		t.Errorf("RootNamespace not templated in %q", got)
+	}
+	// files outside the .NET languages are left alone
+	if got, want := out["package.json"], "{\"name\": \"Web\"}\n"; got != want {
+		t.Errorf("package.json: got %q, want %q", got, want)
+	}
+}
+
+func TestNodeLockfiles(t *testing.T) {
+	manifests := map[string]string{
+		"package.json":               `{"name": "@acme/widget", "workspaces": ["packages/*"]}`,
+		"packages/util/package.json": `{"name": "@acme/util", "dependencies": {"@acme/widget": "^0.9.0"}}`,
+	}
+	tests := []struct {
+		lockfile string
+		source   string
+		want     string
+	}{
+		{
+			lockfile: "package-lock.json",
+			source: `{
+  "name": "@acme/widget",
+  "packages": {
+    "": {"name": "@acme/widget", "workspaces": ["packages/*"]},
+    "node_modules/@acme/util": {"resolved": "packages/util", "link": true},
+    "node_modules/@acme/widget": {
+      "version": "0.9.0",
+      "resolved": "https://registry.npmjs.org/@acme/widget/-/widget-0.9.0.tgz",
+      "integrity": "sha512-acme"
+    },
+    "packages/util": {"name": "@acme/util", "dependencies": {"@acme/widget": "^0.9.0"}}
+  }
+}`,
+			want: `{
+  "name": "@{{ npm_scope }}/{{ npm_name }}",
+  "packages": {
+    "": {"name": "@{{ npm_scope }}/{{ npm_name }}", "workspaces": ["packages/*"]},
+    "node_modules/@{{ npm_scope }}/util": {"resolved": "packages/util", "link": true},
+    "node_modules/@acme/widget": {
+      "version": "0.9.0",
+      "resolved": "https://registry.npmjs.org/@acme/widget/-/widget-0.9.0.tgz",
+      "integrity": "sha512-acme"
+    },
+    "packages/util": {"name": "@{{ npm_scope }}/util", "dependencies": {"@{{ npm_scope }}/{{ npm_name }}": "^0.9.0"}}
+  }
+}`,
+		},
+		{
+			lockfile: "pnpm-lock.yaml",
+			source: "importers:\n" +
+				"  .:\n" +
+				"    dependencies:\n" +
+				"      '@acme/util':\n" +
+				"        version: link:packages/util\n" +
+				"  packages/util:\n" +
+				"    dependencies:\n" +
+				"      '@acme/widget':\n" +
+				"        version: 0.9.0\n" +
+				"packages:\n" +
+				"  /@acme/widget@0.9.0:\n" +
+				"    resolution: {tarball: 'https://registry.npmjs.org/@acme/widget/-/widget-0.9.0.tgz'}\n",
+			want: "importers:\n" +
+				"  .:\n" +
+				"    dependencies:\n" +
+				"      '@{{ npm_scope }}/util':\n" +
+				"        version: link:packages/util\n" +
+				"  packages/util:\n" +
+				"    dependencies:\n" +
+				"      '@{{ npm_scope }}/{{ npm_name }}':\n" +
+				"        version: 0.9.0\n" +
+				"packages:\n" +
+				"  /@acme/widget@0.9.0:\n" +
+				"    resolution: {tarball: 'https://registry.npmjs.org/@acme/widget/-/widget-0.9.0.tgz'}\n",
+		},
+		{
+			lockfile: "yarn.lock",
+			source: "\"@acme/util@workspace:packages/util\":\n" +
+				"  resolution: \"@acme/util@workspace:packages/util\"\n" +
+				"  dependencies:\n" +
+				"    \"@acme/widget\": ^0.9.0\n" +
+				"\n" +
+				"\"@acme/widget@npm:^0.9.0\":\n" +
+				"  resolution: \"@acme/widget@npm:0.9.0\"\n" +
+				"  resolved \"https://registry.yarnpkg.com/@acme/widget/-/widget-0.9.0.tgz\"\n",
+			want: "\"@{{ npm_scope }}/util@workspace:packages/util\":\n" +
+				"  resolution: \"@{{ npm_scope }}/util@workspace:packages/util\"\n" +
+				"  dependencies:\n" +
+				"    \"@{{ npm_scope }}/{{ npm_name }}\": ^0.9.0\n" +
+				"\n" +
+				"\"@acme/widget@npm:^0.9.0\":\n" +
+				"  resolution: \"@acme/widget@npm:0.9.0\"\n" +
+				"  resolved \"https://registry.yarnpkg.com/@acme/widget/-/widget-0.9.0.tgz\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lockfile, func(t *testing.T) {
+			files := maps.Clone(manifests)
+			files[tt.lockfile] = tt.source
+			_, out := applyEcosystems(t, "ecosystems:\n  node: {}\n", files)
+			if out[tt.lockfile] != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out[tt.lockfile], tt.want)
+			}
+			want := `{"name": "@{{ npm_scope }}/util", "dependencies": {"@{{ npm_scope }}/{{ npm_name }}": "^0.9.0"}}`
+			if got := out["packages/util/package.json"]; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRustCrate(t *testing.T) {
+	s, out := applyEcosystems(t, "ecosystems:\n  rust: {}\n", map[string]string{
+		"Cargo.toml": "[package]\nname = \"acme-widget\"\n\n" +
+			"[workspace]\nmembers = [\"crates/acme-widget-core\"]\n\n" +
+			"[dependencies]\nacme-widget-core = { path = \"crates/acme-widget-core\" }\n",
+		"crates/acme-widget-core/Cargo.toml": "[package]\nname = \"acme-widget-core\"\n",
+		"src/main.rs": "use acme_widget_core::Engine;\n\n" +
+			"fn main() {\n    let name = \"acme-widget-tools\";\n}\n",
+		"package.json": `{"name": "acme-widget"}`,
+	})
+
+	if v := s.Variables["crate_name"]; v == nil || v.Default != "acme-widget" {
+		t.Errorf("crate_name variable = %+v, want the crate as default", v)
+	}
+	want := "[package]\nname = \"{{ crate_name }}\"\n\n" +
+		"[workspace]\nmembers = [\"crates/{{ crate_name }}-core\"]\n\n" +
+		"[dependencies]\n{{ crate_name }}-core = { path = \"crates/{{ crate_name }}-core\" }\n"
+	if got := out["Cargo.toml"]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	want = "use {{ crate_name_ident }}_core::Engine;\n\n" +
+		"fn main() {\n    let name = \"acme-widget-tools\";\n}\n"
+	if got := out["src/main.rs"]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// other ecosystems' manifests are left alone
+	if got := out["package.json"]; got != `{"name": "acme-widget"}` {
+		t.Errorf("package.json changed: %q", got)
+	}
+	if got := filepath.ToSlash(s.TemplatePath(filepath.FromSlash("crates/acme-widget-core/src/lib.rs"))); got != "crates/{{ crate_name }}-core/src/lib.rs" {
+		t.Errorf("TemplatePath = %q, want the member folder renamed", got)
+	}
+	if !slices.Contains(s.Exclude, "Cargo.lock") {
+		t.Errorf("excludes %v, want Cargo.lock", s.Exclude)
+	}
+}
@@ -1,8 +1,15 @@
 package spec
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/tnaucoin/mintmpl/internal/languages"
 	"go.yaml.in/yaml/v3"
@@ -15,6 +22,56 @@ type Spec struct {
 	ConditionalPaths map[string]string          `yaml:"conditional_paths"`
 	Exclude          []string                   `yaml:"exclude"`
 	NoTransform      []string                   `yaml:"no_transform"`
+	Languages        map[string]*LanguageSpec   `yaml:"languages"`
+	// AutoLanguages enables heuristic handling of files outside the language table
+	AutoLanguages bool `yaml:"auto_languages"`
+	// LanguageOverrides maps path globs to the language their files are parsed as
+	LanguageOverrides map[string]string `yaml:"language_overrides"`
+	// StripNotebookOutputs clears cell outputs and execution counts of notebooks
+	StripNotebookOutputs bool `yaml:"strip_notebook_outputs"`
+	// Tasks are commands Copier runs in the generated project
+	Tasks []string `yaml:"tasks"`
+	// Ecosystems enables package-aware renaming for language ecosystems
+	Ecosystems Ecosystems `yaml:"ecosystems"`
+
+	ecosystemTransforms []Transform
+	pathRewrites        []pathRewrite
+	nameRewrites        []nameRewrite
+
+	table *languages.Table
+}
+
+// LanguageSpec declares a new language backed by a go-sitter-forest grammar,
+// or extends a built-in one with more files and adjusted categories.
+type LanguageSpec struct {
+	Extensions []string                    `yaml:"extensions"`
+	Filenames  []string                    `yaml:"filenames"`
+	Grammar    string                      `yaml:"grammar"`
+	Categories map[string]CategoryOverride `yaml:"categories"`
+	Injections []InjectionConfig           `yaml:"injections"`
+}
+
+// InjectionConfig declares code of another language embedded in host nodes,
+// e.g. SQL inside Python strings.
+type InjectionConfig struct {
+	NodeType          string   `yaml:"node_type"`
+	Language          string   `yaml:"language"`
+	LanguageNode      string   `yaml:"language_node"`
+	LanguageAttribute string   `yaml:"language_attribute"`
+	ContentNode       string   `yaml:"content_node"`
+	Key               string   `yaml:"key"`
+	Contains          string   `yaml:"contains"`
+	Paths             []string `yaml:"paths"`
+}
+
+// Config holds user-wide settings shared by every spec
+type Config struct {
+	Languages map[string]*LanguageSpec `yaml:"languages"`
+}
+
+type CategoryOverride struct {
+	Add    []string `yaml:"add"`
+	Remove []string `yaml:"remove"`
 }
 
 type VariableConfig struct {
@@ -23,6 +80,10 @@ type VariableConfig struct {
 	Default     any               `yaml:"default"`
 	Choices     []string          `yaml:"choices"`
 	Transforms  []TransformConfig `yaml:"transforms"`
+	Repeat      []RepeatConfig    `yaml:"repeat"`
+	// When is Copier's condition for asking the question; false makes the
+	// variable computed from its default
+	When any `yaml:"when"`
 }
 
 type TransformConfig struct {
@@ -31,6 +92,20 @@ type TransformConfig struct {
 	Filter        string   `yaml:"filter"`
 	CaseSensitive *bool    `yaml:"case_sensitive"`
 	ExactMatch    bool     `yaml:"exact_match"`
+	Paths         []string `yaml:"paths"`
+	ExcludePaths  []string `yaml:"exclude_paths"`
+	Languages     []string `yaml:"languages"`
+}
+
+// RepeatConfig marks a source region as the body of a loop over a list variable.
+// The region is the innermost NodeType node containing Match, or the line
+// containing Match when NodeType is empty.
+type RepeatConfig struct {
+	Match    string            `yaml:"match"`
+	NodeType string            `yaml:"node_type"`
+	Item     string            `yaml:"item"`
+	Value    string            `yaml:"value"`
+	Fields   map[string]string `yaml:"fields"`
 }
 
 type Transform struct {
@@ -39,6 +114,35 @@ type Transform struct {
 	NodeTypes     []languages.NodeCategory
 	CaseSensitive bool
 	ExactMatch    bool
+	Paths         []string
+	ExcludePaths  []string
+	Languages     []string
+	// WholePath only matches Match where it isn't part of a longer name, so
+	// github.com/acme/widget matches in github.com/acme/widget/api but not
+	// in github.com/acme/widget-tools
+	WholePath bool
+	// Standalone narrows WholePath to matches that aren't part of a longer
+	// path, URL or version either: "/" or "@" may not precede or follow them
+	Standalone bool
+}
+
+// AppliesTo reports whether the transform is scoped to the given file path
+// (relative to the source root) and language name.
+func (t Transform) AppliesTo(path, language string) bool {
+	if len(t.Languages) > 0 && !slices.Contains(t.Languages, language) {
+		return false
+	}
+	if len(t.Paths) > 0 && !MatchPath(path, t.Paths) {
+		return false
+	}
+	return !MatchPath(path, t.ExcludePaths)
+}
+
+type Repeat struct {
+	Match    string
+	NodeType string
+	LoopTag  string
+	Fields   []Transform
 }
 
 func Load(path string) (*Spec, error) {
@@ -59,14 +163,133 @@ func Load(path string) (*Spec, error) {
 		spec.Version = "1.0.0"
 	}
 
+	config, err := LoadConfig(ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.applyLanguages(config.Languages); err != nil {
+		return nil, fmt.Errorf("global config: %w", err)
+	}
+	if err := spec.applyLanguages(spec.Languages); err != nil {
+		return nil, err
+	}
+
 	return &spec, nil
 }
 
+// ConfigPath returns the location of the global config file, which can be
+// overridden with MINTMPL_CONFIG.
+func ConfigPath() string {
+	if path := os.Getenv("MINTMPL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mintmpl", "config.yml")
+}
+
+// LoadConfig reads the global config file. A missing file yields an empty config.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if path == "" {
+		return &config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading global config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing global config %w", err)
+	}
+	return &config, nil
+}
+
+// LanguageTable returns the languages of the spec: the built-in ones along
+// with those declared in the global config and the spec
+func (s *Spec) LanguageTable() *languages.Table {
+	if s.table == nil {
+		s.table = languages.Builtin()
+	}
+	return s.table
+}
+
+// applyLanguages registers declared languages and merges category overrides
+// into the spec's language table
+func (s *Spec) applyLanguages(langSpecs map[string]*LanguageSpec) error {
+	for name, langSpec := range langSpecs {
+		if langSpec == nil {
+			continue
+		}
+
+		langConfig, ok := s.LanguageTable().Languages[name]
+		if ok {
+			if langSpec.Grammar != "" && langSpec.Grammar != langConfig.Grammar {
+				if langConfig.Grammar == "" {
+					return fmt.Errorf("languages: %s: grammar of a built-in language cannot be changed", name)
+				}
+				return fmt.Errorf("languages: %s: grammar %q conflicts with %q declared earlier", name, langSpec.Grammar, langConfig.Grammar)
+			}
+			langConfig.Extensions = append(langConfig.Extensions, langSpec.Extensions...)
+			langConfig.Filenames = append(langConfig.Filenames, langSpec.Filenames...)
+		} else {
+			grammarName := langSpec.Grammar
+			if grammarName == "" {
+				grammarName = name
+			}
+			grammar, err := languages.Grammar(grammarName)
+			if err != nil {
+				return fmt.Errorf("languages: %s: %w", name, err)
+			}
+			langConfig = &languages.LanguageConfig{
+				Name:       name,
+				Extensions: langSpec.Extensions,
+				Filenames:  langSpec.Filenames,
+				Language:   grammar,
+				Grammar:    grammarName,
+			}
+		}
+		s.LanguageTable().Register(langConfig)
+
+		for cat, override := range langSpec.Categories {
+			if languages.NodeCategory(cat) == languages.CategoryAny {
+				return fmt.Errorf("languages: %s: category %q cannot be overridden", name, cat)
+			}
+			langConfig.OverrideCategory(languages.NodeCategory(cat), override.Add, override.Remove)
+		}
+
+		for _, inj := range langSpec.Injections {
+			if inj.NodeType == "" || (inj.Language == "" && inj.LanguageNode == "" && inj.LanguageAttribute == "") {
+				return fmt.Errorf("languages: %s: injections need a node_type and a language, language_node or language_attribute", name)
+			}
+			langConfig.Injections = append(langConfig.Injections, languages.Injection{
+				NodeType:          inj.NodeType,
+				Language:          inj.Language,
+				LanguageNode:      inj.LanguageNode,
+				LanguageAttribute: inj.LanguageAttribute,
+				ContentNode:       inj.ContentNode,
+				Key:               inj.Key,
+				Contains:          inj.Contains,
+				Paths:             inj.Paths,
+			})
+		}
+	}
+	return nil
+}
+
+// BuildTransforms returns the transforms of the variables, longest match
+// first so a value claims a node before one nested in it, followed by those
+// of the ecosystems.
 func (s *Spec) BuildTransforms() []Transform {
 	var transforms []Transform
 
-	for varName, varConfig := range s.Variables {
-		for _, t := range varConfig.Transforms {
+	for _, varName := range slices.Sorted(maps.Keys(s.Variables)) {
+		for _, t := range s.Variables[varName].Transforms {
 			nodeTypes := make([]languages.NodeCategory, 0, len(t.NodeTypes))
 			for _, nt := range t.NodeTypes {
 				if nt == "" {
@@ -97,10 +320,192 @@ func (s *Spec) BuildTransforms() []Transform {
 				NodeTypes:     nodeTypes,
 				CaseSensitive: caseSensitive,
 				ExactMatch:    t.ExactMatch,
+				Paths:         t.Paths,
+				ExcludePaths:  t.ExcludePaths,
+				Languages:     t.Languages,
+			})
+		}
+	}
+	slices.SortStableFunc(transforms, func(a, b Transform) int {
+		return len(b.Match) - len(a.Match)
+	})
+	return append(transforms, s.ecosystemTransforms...)
+}
+
+func (s *Spec) BuildRepeats() []Repeat {
+	var repeats []Repeat
+
+	for _, varName := range slices.Sorted(maps.Keys(s.Variables)) {
+		for _, r := range s.Variables[varName].Repeat {
+			item := r.Item
+			if item == "" {
+				item = "item"
+			}
+
+			var fields []Transform
+			if r.Value != "" {
+				fields = append(fields, Transform{
+					Match:         r.Value,
+					Replace:       fmt.Sprintf("{{ %s }}", item),
+					CaseSensitive: true,
+				})
+			}
+			for _, field := range slices.Sorted(maps.Keys(r.Fields)) {
+				fields = append(fields, Transform{
+					Match:         r.Fields[field],
+					Replace:       fmt.Sprintf("{{ %s.%s }}", item, field),
+					CaseSensitive: true,
+				})
+			}
+			// replace longer literals first so a field value that contains
+			// another one is not split apart
+			slices.SortStableFunc(fields, func(a, b Transform) int {
+				return len(b.Match) - len(a.Match)
+			})
+
+			repeats = append(repeats, Repeat{
+				Match:    r.Match,
+				NodeType: r.NodeType,
+				LoopTag:  fmt.Sprintf("{%% for %s in %s %%}", item, varName),
+				Fields:   fields,
 			})
 		}
 	}
-	return transforms
+	return repeats
+}
+
+// Validate checks the spec against the known languages and their grammars and
+// returns a description of every problem found.
+func (s *Spec) Validate() []string {
+	var problems []string
+
+	names := make([]string, 0, len(s.Variables))
+	for name := range s.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range slices.Sorted(maps.Keys(s.Languages)) {
+		langConfig := s.LanguageTable().Languages[name]
+		if langConfig == nil || s.Languages[name] == nil || langConfig.Language == nil {
+			continue
+		}
+		for _, cat := range slices.Sorted(maps.Keys(s.Languages[name].Categories)) {
+			for _, nt := range s.Languages[name].Categories[cat].Add {
+				if !langConfig.HasNodeType(nt) {
+					problems = append(problems, fmt.Sprintf("languages: %s: category %q: unknown node type %q", name, cat, nt))
+				}
+			}
+		}
+	}
+
+	for _, pattern := range slices.Sorted(maps.Keys(s.LanguageOverrides)) {
+		if s.LanguageTable().ResolveLanguage(s.LanguageOverrides[pattern]) == nil {
+			problems = append(problems, fmt.Sprintf("language_overrides: %q: unknown language %q", pattern, s.LanguageOverrides[pattern]))
+		}
+	}
+
+	for _, varName := range names {
+		for i, t := range s.Variables[varName].Transforms {
+			prefix := fmt.Sprintf("variable %q transform %d", varName, i+1)
+			for _, nt := range t.NodeTypes {
+				if problem := s.validateNodeType(languages.NodeCategory(nt)); problem != "" {
+					problems = append(problems, fmt.Sprintf("%s: %s", prefix, problem))
+				}
+			}
+			for _, lang := range t.Languages {
+				if _, ok := s.LanguageTable().Languages[lang]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: unknown language %q", prefix, lang))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// validateNodeType checks a category or raw node type. Raw node types must
+// name a language as MatchesCategory compares it: its exact table name, or a
+// grammar name when auto languages are enabled.
+func (s *Spec) validateNodeType(nt languages.NodeCategory) string {
+	lang, rawType, ok := languages.ParseRawNodeType(nt)
+	if !ok {
+		if nt == "" || s.LanguageTable().IsCategory(nt) {
+			return ""
+		}
+		return fmt.Sprintf("unknown node category %q", nt)
+	}
+
+	langConfig := s.LanguageTable().Languages[lang]
+	if langConfig == nil && s.AutoLanguages {
+		if resolved := s.LanguageTable().ResolveLanguage(lang); resolved != nil && resolved.Name == lang {
+			langConfig = resolved
+		}
+	}
+	if langConfig == nil {
+		if resolved := s.LanguageTable().Lookup(lang); resolved != nil {
+			return fmt.Sprintf("unknown language %q in node type %q (use %q)", lang, nt, resolved.Name)
+		}
+		return fmt.Sprintf("unknown language %q in node type %q", lang, nt)
+	}
+	if langConfig.Language == nil {
+		return fmt.Sprintf("language %q has no grammar for node type %q", lang, nt)
+	}
+	if !langConfig.HasNodeType(rawType) {
+		return fmt.Sprintf("unknown node type %q for language %q", rawType, lang)
+	}
+	return ""
+}
+
+// MatchPath reports whether path matches any of the patterns. A pattern
+// matches the path itself, its base name, or, when it ends in "/" or "/**",
+// anything below that directory. "**" matches any number of path segments.
+func MatchPath(path string, patterns []string) bool {
+	path = filepath.ToSlash(path)
+	for _, pattern := range patterns {
+		if path == pattern {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, "/**") {
+			prefix := strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/")
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		}
+
+		if strings.Contains(pattern, "**") && matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/")) {
+			return true
+		}
+
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if matched, _ := filepath.Match(pattern[0], path[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
 }
 
 func GetDefaultExcludes() []string {
@@ -0,0 +1,235 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+)
+
+func TestBuildRepeatsIsDeterministic(t *testing.T) {
+	s := &Spec{Variables: map[string]*VariableConfig{
+		"services": {Repeat: []RepeatConfig{{
+			Match: "billing",
+			Value: "billing",
+			Fields: map[string]string{
+				"port": "8081", "host": "bill", "path": "/api", "team": "core",
+			},
+		}}},
+	}}
+	// equal lengths keep the order of the field names
+	want := []string{"billing", "bill", "/api", "8081", "core"}
+	for range 20 {
+		var got []string
+		for _, f := range s.BuildRepeats()[0].Fields {
+			got = append(got, f.Match)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got fields %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		pattern string
+		want    bool
+	}{
+		{"wwwroot/lib/jquery.js", "wwwroot/lib/", true},
+		{"wwwroot/lib/dist/jquery.js", "wwwroot/lib/**", true},
+		{"wwwroot/library.js", "wwwroot/lib/**", false},
+		{"src/Acme/Program.cs", "**/*.cs", true},
+		{"src/Acme/Program.cs", "*.cs", true},
+		{"src/Acme/Program.cs", "src/*.cs", false},
+		{"src/Acme/Program.cs", "src/**/Program.cs", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.path, []string{tt.pattern}); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.path, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	transform := Transform{
+		Paths:        []string{"src/**"},
+		ExcludePaths: []string{"src/wwwroot/lib/"},
+		Languages:    []string{"csharp", "javascript"},
+	}
+	tests := []struct {
+		path, language string
+		want           bool
+	}{
+		{"src/Acme/Program.cs", "csharp", true},
+		{"src/wwwroot/app.js", "javascript", true},
+		{"src/wwwroot/lib/jquery.js", "javascript", false},
+		{"src/appsettings.json", "json", false},
+		{"tests/Acme.Tests/Test.cs", "csharp", false},
+	}
+	for _, tt := range tests {
+		if got := transform.AppliesTo(tt.path, tt.language); got != tt.want {
+			t.Errorf("AppliesTo(%q, %q) = %v, want %v", tt.path, tt.language, got, tt.want)
+		}
+	}
+}
+
+// writeSpec writes a spec file to a temporary directory and returns its path
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".mintmpl.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLanguageOverridesStayInTheSpec(t *testing.T) {
+	path := writeSpec(t, "name: demo\n"+
+		"languages:\n"+
+		"  json:\n"+
+		"    categories:\n"+
+		"      identifier:\n"+
+		"        add: [string_content]\n"+
+		"      key:\n"+
+		"        add: [pair]\n")
+	for range 2 {
+		s, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.LanguageTable().Languages["json"].IdentifierTypes; !slices.Equal(got, []string{"string_content"}) {
+			t.Errorf("json identifier types = %q, want the override once", got)
+		}
+	}
+
+	other, err := Load(writeSpec(t, "name: other\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []*languages.Table{other.LanguageTable(), {Languages: languages.Languages}} {
+		if got := table.Languages["json"].IdentifierTypes; len(got) != 0 {
+			t.Errorf("json identifier types = %q, want none", got)
+		}
+		if table.IsCategory("key") {
+			t.Error("category key leaked out of the spec")
+		}
+	}
+}
+
+func TestSpecRedeclaresConfigLanguage(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(config, []byte("languages:\n"+
+		"  starlark:\n"+
+		"    grammar: starlark\n"+
+		"    extensions: [bzl]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MINTMPL_CONFIG", config)
+
+	path := writeSpec(t, "name: demo\n"+
+		"languages:\n"+
+		"  starlark:\n"+
+		"    grammar: starlark\n"+
+		"    extensions: [star]\n")
+	for range 2 {
+		s, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := s.LanguageTable().Languages["starlark"].Extensions
+		if want := []string{".bzl", ".star"}; !slices.Equal(got, want) {
+			t.Errorf("starlark extensions = %q, want %q", got, want)
+		}
+		for _, file := range []string{"defs.bzl", "rules.star"} {
+			if lang := s.LanguageTable().GetLanguageForFile(file); lang == nil || lang.Name != "starlark" {
+				t.Errorf("%s is not starlark", file)
+			}
+		}
+	}
+
+	tests := []struct {
+		spec, want string
+	}{
+		{"  starlark:\n    grammar: python\n", `languages: starlark: grammar "python" conflicts with "starlark" declared earlier`},
+		{"  python:\n    grammar: starlark\n", "languages: python: grammar of a built-in language cannot be changed"},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeSpec(t, "name: demo\nlanguages:\n"+tt.spec))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("got error %v, want %q", err, tt.want)
+		}
+	}
+}
+
+func TestValidateNodeType(t *testing.T) {
+	tests := []struct {
+		nodeType string
+		auto     bool
+		valid    bool
+	}{
+		{"string", false, true},
+		{"csharp:using_directive", false, true},
+		{"python:decorator", false, true},
+		// aliases are resolved for files, but MatchesCategory compares the
+		// table name
+		{"c_sharp:using_directive", false, false},
+		{"py:decorator", false, false},
+		// grammars outside the table only apply in auto mode
+		{"zig:identifier", false, false},
+		{"zig:identifier", true, true},
+		{"csharp:no_such_node", false, false},
+		{"no_such_category", false, false},
+	}
+	for _, tt := range tests {
+		s := &Spec{AutoLanguages: tt.auto}
+		problem := s.validateNodeType(languages.NodeCategory(tt.nodeType))
+		if valid := problem == ""; valid != tt.valid {
+			t.Errorf("validateNodeType(%q, auto=%v) = %q, want valid=%v", tt.nodeType, tt.auto, problem, tt.valid)
+		}
+	}
+}
+
+func TestBuildTransformsLongestMatchFirst(t *testing.T) {
+	s := &Spec{Variables: map[string]*VariableConfig{
+		"org":          {Transforms: []TransformConfig{{Match: "acme"}}},
+		"project_name": {Transforms: []TransformConfig{{Match: "acme-widget"}}},
+		"module":       {Transforms: []TransformConfig{{Match: "widget"}}},
+	}}
+	var got []string
+	for _, transform := range s.BuildTransforms() {
+		got = append(got, transform.Match)
+	}
+	if want := []string{"acme-widget", "widget", "acme"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSectionName(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pyproject.toml": "[build-system]\nrequires = [\"hatchling\"]\n\n[project]\nname = \"acme-widget\"  # dist\n",
+		"setup.cfg":      "[metadata]\nname = acme-widget\n",
+		"Cargo.toml":     "[[bin]]\nname = \"widget-cli\"\n\n[package]\nname = 'acme-widget'\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		file     string
+		sections []string
+	}{
+		{"pyproject.toml", []string{"tool.poetry", "project"}},
+		{"setup.cfg", []string{"metadata"}},
+		{"Cargo.toml", []string{"package"}},
+	}
+	for _, tt := range tests {
+		if got := sectionName(filepath.Join(dir, tt.file), tt.sections...); got != "acme-widget" {
+			t.Errorf("sectionName(%s, %q) = %q, want acme-widget", tt.file, tt.sections, got)
+		}
+	}
+}
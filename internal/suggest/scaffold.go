@@ -0,0 +1,137 @@
+package suggest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+	"github.com/tnaucoin/mintmpl/internal/transformer"
+	"go.yaml.in/yaml/v3"
+)
+
+// buildFolders are build output, virtualenv and cache folders that rarely
+// belong in a template
+var buildFolders = []string{
+	"dist", "build", "out", "target", "coverage", "htmlcov",
+	".venv", "venv", ".tox", ".nox", ".pytest_cache", ".mypy_cache", ".ruff_cache",
+	".next", ".nuxt", ".gradle", ".terraform",
+}
+
+// Layout is what a walk of the source tree found
+type Layout struct {
+	Files int
+	// Languages counts the files of each language
+	Languages map[string]int
+	// Excludes are folders that look like build output or caches, as exclude
+	// patterns relative to the source
+	Excludes []string
+}
+
+// LanguageNames returns the languages found, most files first
+func (l *Layout) LanguageNames() []string {
+	names := make([]string, 0, len(l.Languages))
+	for name := range l.Languages {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if l.Languages[a] != l.Languages[b] {
+			return l.Languages[b] - l.Languages[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return names
+}
+
+// Survey walks the source tree, skipping the default excludes and those of
+// templateSpec, to find its languages and the folders worth excluding.
+func Survey(sourceDir string, templateSpec *spec.Spec) (*Layout, error) {
+	trans := transformer.New(nil, nil)
+	trans.SetLanguages(templateSpec.LanguageTable())
+	trans.SetAutoLanguages(templateSpec.AutoLanguages)
+	trans.SetLanguageOverrides(templateSpec.LanguageOverrides)
+	excludes := append(spec.GetDefaultExcludes(), templateSpec.Exclude...)
+
+	layout := &Layout{Languages: make(map[string]int)}
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if spec.MatchPath(relPath, excludes) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if slices.Contains(buildFolders, d.Name()) || strings.HasSuffix(d.Name(), ".egg-info") {
+				layout.Excludes = append(layout.Excludes, filepath.ToSlash(relPath)+"/")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		layout.Files++
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if name := trans.LanguageName(relPath, content); name != "" {
+			layout.Languages[name]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// RenderSpec returns a commented .mintmpl.yml declaring the suggestions as
+// variables and excluding the given patterns.
+func RenderSpec(name string, layout *Layout, suggestions []Suggestion, excludes []string) ([]byte, error) {
+	var langs []string
+	for _, lang := range layout.LanguageNames() {
+		langs = append(langs, fmt.Sprintf("%s (%d file(s))", lang, layout.Languages[lang]))
+	}
+	header := "Generated by mintmpl init, review before use and check with mintmpl validate"
+	if len(langs) > 0 {
+		header += "\nLanguages found: " + strings.Join(langs, ", ")
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	doc.Content = append(doc.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "name", HeadComment: header},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+	)
+
+	if len(suggestions) > 0 {
+		vars, err := variablesNode(suggestions)
+		if err != nil {
+			return nil, err
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "variables", HeadComment: "Each transform replaces its match with the variable in the node types listed"},
+			vars,
+		)
+	}
+
+	if len(excludes) > 0 {
+		list := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, pattern := range excludes {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: pattern})
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "exclude", HeadComment: "Build output and caches, on top of the default excludes"},
+			list,
+		)
+	}
+	return encode(doc)
+}
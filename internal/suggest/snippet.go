@@ -0,0 +1,256 @@
+package suggest
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+	"github.com/tnaucoin/mintmpl/internal/transformer"
+	"go.yaml.in/yaml/v3"
+)
+
+// Suggestion is a candidate with where it occurs in the source tree
+type Suggestion struct {
+	Candidate
+	Occurrences []transformer.Occurrence
+	// Samples are the first lines containing the value, as "path:line: text"
+	Samples []string
+}
+
+// maxSamples is how many sample lines are kept per suggestion
+const maxSamples = 3
+
+// Total returns the number of occurrences of the candidate
+func (s Suggestion) Total() int {
+	total := 0
+	for _, o := range s.Occurrences {
+		total += o.Count
+	}
+	return total
+}
+
+// NodeTypes returns the categories the candidate occurs in, most frequent
+// first. Plaintext occurrences need none, as transforms apply to all text.
+func (s Suggestion) NodeTypes() []languages.NodeCategory {
+	counts := make(map[languages.NodeCategory]int)
+	for _, o := range s.Occurrences {
+		if o.Category != "" {
+			counts[o.Category] += o.Count
+		}
+	}
+	cats := make([]languages.NodeCategory, 0, len(counts))
+	for cat := range counts {
+		cats = append(cats, cat)
+	}
+	slices.SortFunc(cats, func(a, b languages.NodeCategory) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	return cats
+}
+
+// Count walks the source tree like generate does, with the excludes and
+// language settings of templateSpec, and counts where each candidate occurs.
+// Candidates occurring nowhere are dropped.
+func Count(sourceDir string, templateSpec *spec.Spec, candidates []Candidate) ([]Suggestion, error) {
+	values := make([]string, len(candidates))
+	for i, c := range candidates {
+		values[i] = c.Value
+	}
+
+	trans := transformer.New(nil, nil)
+	trans.SetLanguages(templateSpec.LanguageTable())
+	trans.SetAutoLanguages(templateSpec.AutoLanguages)
+	trans.SetLanguageOverrides(templateSpec.LanguageOverrides)
+	excludes := append(spec.GetDefaultExcludes(), templateSpec.Exclude...)
+
+	byValue := make(map[string][]transformer.Occurrence)
+	samples := make(map[string][]string)
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if spec.MatchPath(relPath, excludes) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		// occurrences come per category, so a file is sampled once per value
+		inFile := make(map[string]bool)
+		for _, o := range trans.CountOccurrences(relPath, content, values) {
+			if !inFile[o.Value] && len(samples[o.Value]) < maxSamples {
+				inFile[o.Value] = true
+				samples[o.Value] = append(samples[o.Value], sampleLines(relPath, content, o.Value, maxSamples-len(samples[o.Value]))...)
+			}
+			byValue[o.Value] = append(byValue[o.Value], o)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []Suggestion
+	for _, c := range candidates {
+		if occurrences := mergeOccurrences(byValue[c.Value]); len(occurrences) > 0 {
+			suggestions = append(suggestions, Suggestion{Candidate: c, Occurrences: occurrences, Samples: samples[c.Value]})
+		}
+	}
+	return suggestions, nil
+}
+
+// sampleLines returns up to n lines of content containing value
+func sampleLines(relPath string, content []byte, value string, n int) []string {
+	var lines []string
+	for i, line := range strings.Split(string(content), "\n") {
+		if len(lines) == n {
+			break
+		}
+		if strings.Contains(line, value) {
+			line = strings.TrimSpace(line)
+			if len(line) > 100 {
+				line = line[:100] + "..."
+			}
+			lines = append(lines, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(relPath), i+1, line))
+		}
+	}
+	return lines
+}
+
+// mergeOccurrences sums the per file occurrences by language and category,
+// most frequent first
+func mergeOccurrences(occurrences []transformer.Occurrence) []transformer.Occurrence {
+	var merged []transformer.Occurrence
+	for _, o := range occurrences {
+		i := slices.IndexFunc(merged, func(m transformer.Occurrence) bool {
+			return m.Language == o.Language && m.Category == o.Category
+		})
+		if i < 0 {
+			merged = append(merged, o)
+			continue
+		}
+		merged[i].Count += o.Count
+	}
+	slices.SortStableFunc(merged, func(a, b transformer.Occurrence) int {
+		return b.Count - a.Count
+	})
+	return merged
+}
+
+// Render returns a .mintmpl.yml snippet declaring a variable with a transform
+// for each suggestion, commented with where its value was found.
+func Render(suggestions []Suggestion) ([]byte, error) {
+	vars, err := variablesNode(suggestions)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "variables", HeadComment: "Suggested by mintmpl suggest, review before use"},
+		vars,
+	}}
+	return encode(doc)
+}
+
+// variablesNode returns the variables mapping of a spec declaring the
+// suggestions
+func variablesNode(suggestions []Suggestion) (*yaml.Node, error) {
+	vars := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range suggestions {
+		var nodeTypes []string
+		for _, cat := range s.NodeTypes() {
+			nodeTypes = append(nodeTypes, string(cat))
+		}
+		transform := map[string]any{"match": s.Value}
+		if len(nodeTypes) > 0 {
+			transform["node_types"] = nodeTypes
+		}
+
+		var def yaml.Node
+		if err := def.Encode(struct {
+			Type        string           `yaml:"type"`
+			Description string           `yaml:"description"`
+			Default     string           `yaml:"default"`
+			Transforms  []map[string]any `yaml:"transforms"`
+		}{"str", s.Description, s.Value, []map[string]any{transform}}); err != nil {
+			return nil, err
+		}
+		// flow style keeps node_types on one line, ready to edit
+		for _, n := range def.Content[len(def.Content)-1].Content[0].Content {
+			if n.Kind == yaml.SequenceNode {
+				n.Style = yaml.FlowStyle
+			}
+		}
+
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: s.Variable, HeadComment: Describe(s)}
+		vars.Content = append(vars.Content, key, &def)
+	}
+	return vars, nil
+}
+
+func encode(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Describe summarizes where a suggestion was found and how often it occurs,
+// such as "from go.mod: 7 in go (namespace 4, string 2), 1 in plaintext"
+func Describe(s Suggestion) string {
+	var langs []string
+	byLang := make(map[string][]transformer.Occurrence)
+	for _, o := range s.Occurrences {
+		if _, ok := byLang[o.Language]; !ok {
+			langs = append(langs, o.Language)
+		}
+		byLang[o.Language] = append(byLang[o.Language], o)
+	}
+
+	var parts []string
+	for _, lang := range langs {
+		total := 0
+		var cats []string
+		for _, o := range byLang[lang] {
+			total += o.Count
+			if o.Category != "" {
+				cats = append(cats, fmt.Sprintf("%s %d", o.Category, o.Count))
+			}
+		}
+		part := fmt.Sprintf("%d in %s", total, lang)
+		if len(cats) > 0 {
+			part += " (" + strings.Join(cats, ", ") + ")"
+		}
+		parts = append(parts, part)
+	}
+	if len(s.Sources) == 0 {
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprintf("from %s: %s", strings.Join(s.Sources, ", "), strings.Join(parts, ", "))
+}
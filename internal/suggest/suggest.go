@@ -0,0 +1,322 @@
+// Package suggest proposes template variables from the manifests of a
+// source tree, such as go.mod, package.json or Cargo.toml.
+package suggest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+// Candidate is a value found in a manifest that likely belongs in a variable
+type Candidate struct {
+	Variable    string
+	Description string
+	Value       string
+	// Sources are the files the value was read from
+	Sources []string
+}
+
+// Variables proposed for the values found, in the order they are printed
+const (
+	VarProjectName   = "project_name"
+	VarOrg           = "org"
+	VarAuthorName    = "author_name"
+	VarAuthorEmail   = "author_email"
+	VarDescription   = "description"
+	VarVersion       = "version"
+	VarLicenseHolder = "license_holder"
+)
+
+var descriptions = map[string]string{
+	VarProjectName:   "Project name",
+	VarOrg:           "Organization or namespace",
+	VarAuthorName:    "Author name",
+	VarAuthorEmail:   "Author email",
+	VarDescription:   "Project description",
+	VarVersion:       "Initial version",
+	VarLicenseHolder: "License copyright holder",
+}
+
+var (
+	quotedRx    = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+	inlineRx    = regexp.MustCompile(`(\w+)\s*=\s*"([^"]*)"`)
+	personRx    = regexp.MustCompile(`^\s*([^<(]*?)\s*(?:<([^>]+)>)?\s*(?:\(([^)]+)\))?\s*$`)
+	copyrightRx = regexp.MustCompile(`(?mi)^\s*copyright\s+(?:\(c\)\s*|©\s*)?(?:\d{4}(?:\s*[-–,]\s*\d{4})*,?\s+)?(.+?)\s*\.?\s*$`)
+	remoteURLRx = regexp.MustCompile(`[:/]([^/:]+)/([^/]+?)(?:\.git)?/?$`)
+	xmlBlockRx  = regexp.MustCompile(`(?s)<(parent|dependencies|dependencyManagement|build|profiles|plugins)>.*?</(parent|dependencies|dependencyManagement|build|profiles|plugins)>`)
+)
+
+// Scan reads the well-known manifests of sourceDir and returns the candidate
+// values, each once, in the order of the variables above.
+func Scan(sourceDir string) []Candidate {
+	var found []Candidate
+	add := func(source, variable, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		for i, c := range found {
+			if c.Value == value {
+				if !slices.Contains(c.Sources, source) {
+					found[i].Sources = append(found[i].Sources, source)
+				}
+				return
+			}
+		}
+		found = append(found, Candidate{Variable: variable, Value: value, Sources: []string{source}})
+	}
+
+	scanGoMod(sourceDir, add)
+	scanPackageJSON(sourceDir, add)
+	scanPyproject(sourceDir, add)
+	scanCargo(sourceDir, add)
+	scanCsproj(sourceDir, add)
+	scanPom(sourceDir, add)
+	scanLicense(sourceDir, add)
+	scanGitConfig(sourceDir, add)
+
+	return nameCandidates(found)
+}
+
+type addFunc func(source, variable, value string)
+
+func scanGoMod(sourceDir string, add addFunc) {
+	module, err := spec.ReadGoModule(filepath.Join(sourceDir, "go.mod"))
+	if err != nil {
+		return
+	}
+	segments := strings.Split(module, "/")
+	add("go.mod", VarProjectName, segments[len(segments)-1])
+	if len(segments) >= 3 {
+		add("go.mod", VarOrg, segments[1])
+	}
+}
+
+func scanPackageJSON(sourceDir string, add addFunc) {
+	manifest, err := readJSON(filepath.Join(sourceDir, "package.json"))
+	if err != nil {
+		return
+	}
+	if name, ok := manifest["name"].(string); ok {
+		scope, bare, scoped := strings.Cut(strings.TrimPrefix(name, "@"), "/")
+		if scoped && strings.HasPrefix(name, "@") {
+			add("package.json", VarOrg, scope)
+			name = bare
+		}
+		add("package.json", VarProjectName, name)
+	}
+	addString(add, "package.json", VarDescription, manifest["description"])
+	addString(add, "package.json", VarVersion, manifest["version"])
+	switch author := manifest["author"].(type) {
+	case string:
+		addPerson(add, "package.json", author)
+	case map[string]any:
+		addString(add, "package.json", VarAuthorName, author["name"])
+		addString(add, "package.json", VarAuthorEmail, author["email"])
+	}
+}
+
+func scanPyproject(sourceDir string, add addFunc) {
+	sections := spec.ReadSections(filepath.Join(sourceDir, "pyproject.toml"))
+	for _, section := range []string{"project", "tool.poetry"} {
+		values := sections[section]
+		if values == nil {
+			continue
+		}
+		add("pyproject.toml", VarProjectName, unquote(values["name"]))
+		add("pyproject.toml", VarDescription, unquote(values["description"]))
+		add("pyproject.toml", VarVersion, unquote(values["version"]))
+		addAuthors(add, "pyproject.toml", values["authors"])
+	}
+}
+
+func scanCargo(sourceDir string, add addFunc) {
+	values := spec.ReadSections(filepath.Join(sourceDir, "Cargo.toml"))["package"]
+	if values == nil {
+		return
+	}
+	add("Cargo.toml", VarProjectName, unquote(values["name"]))
+	add("Cargo.toml", VarDescription, unquote(values["description"]))
+	add("Cargo.toml", VarVersion, unquote(values["version"]))
+	addAuthors(add, "Cargo.toml", values["authors"])
+}
+
+// scanCsproj reads the project files at the root or in src/<project>/ style
+// folders. The solution name, if any, stands for the project names.
+func scanCsproj(sourceDir string, add addFunc) {
+	solutions, _ := filepath.Glob(filepath.Join(sourceDir, "*.sln"))
+	if len(solutions) == 1 {
+		add(filepath.Base(solutions[0]), VarProjectName, strings.TrimSuffix(filepath.Base(solutions[0]), ".sln"))
+	}
+	projects, _ := filepath.Glob(filepath.Join(sourceDir, "*.csproj"))
+	nested, _ := filepath.Glob(filepath.Join(sourceDir, "*", "*", "*.csproj"))
+	for _, project := range append(projects, nested...) {
+		data, err := os.ReadFile(project)
+		if err != nil {
+			continue
+		}
+		source, _ := filepath.Rel(sourceDir, project)
+		source = filepath.ToSlash(source)
+		if len(solutions) != 1 {
+			name := xmlElement(data, "RootNamespace")
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(project), ".csproj")
+			}
+			add(source, VarProjectName, name)
+		}
+		add(source, VarOrg, xmlElement(data, "Company"))
+		add(source, VarAuthorName, xmlElement(data, "Authors"))
+		add(source, VarDescription, xmlElement(data, "Description"))
+		add(source, VarVersion, xmlElement(data, "Version"))
+	}
+}
+
+func scanPom(sourceDir string, add addFunc) {
+	data, err := os.ReadFile(filepath.Join(sourceDir, "pom.xml"))
+	if err != nil {
+		return
+	}
+	// only the project's own coordinates, not those of its parent or
+	// dependencies
+	data = xmlBlockRx.ReplaceAll(data, nil)
+	add("pom.xml", VarProjectName, xmlElement(data, "artifactId"))
+	add("pom.xml", VarOrg, xmlElement(data, "groupId"))
+	add("pom.xml", VarDescription, xmlElement(data, "description"))
+	add("pom.xml", VarVersion, xmlElement(data, "version"))
+}
+
+func scanLicense(sourceDir string, add addFunc) {
+	for _, name := range []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "COPYING"} {
+		data, err := os.ReadFile(filepath.Join(sourceDir, name))
+		if err != nil {
+			continue
+		}
+		if m := copyrightRx.FindSubmatch(data); m != nil {
+			add(name, VarLicenseHolder, string(m[1]))
+		}
+		return
+	}
+}
+
+// scanGitConfig reads the origin remote of the repository and the user of its
+// local config, falling back to the global one.
+func scanGitConfig(sourceDir string, add addFunc) {
+	sections := readGitConfig(filepath.Join(sourceDir, ".git", "config"))
+	if url := sections[`remote "origin"`]["url"]; url != "" {
+		if m := remoteURLRx.FindStringSubmatch(url); m != nil {
+			add("git config", VarOrg, m[1])
+			add("git config", VarProjectName, m[2])
+		}
+	}
+	user := sections["user"]
+	if user == nil {
+		if home, err := os.UserHomeDir(); err == nil {
+			user = readGitConfig(filepath.Join(home, ".gitconfig"))["user"]
+		}
+	}
+	add("git config", VarAuthorName, user["name"])
+	add("git config", VarAuthorEmail, user["email"])
+}
+
+// nameCandidates sets the variable names and descriptions. Further values
+// for the same variable get a numbered name, such as project_name_2.
+func nameCandidates(found []Candidate) []Candidate {
+	var named []Candidate
+	for _, variable := range []string{VarProjectName, VarOrg, VarAuthorName, VarAuthorEmail, VarDescription, VarVersion, VarLicenseHolder} {
+		n := 0
+		for _, c := range found {
+			if c.Variable != variable {
+				continue
+			}
+			n++
+			c.Description = descriptions[variable]
+			if n > 1 {
+				c.Variable = variable + "_" + strconv.Itoa(n)
+			}
+			named = append(named, c)
+		}
+	}
+	return named
+}
+
+func addString(add addFunc, source, variable string, value any) {
+	if s, ok := value.(string); ok {
+		add(source, variable, s)
+	}
+}
+
+// addPerson adds the name and email of an npm style person, such as
+// "Jane Doe <jane@example.com> (https://example.com)"
+func addPerson(add addFunc, source, person string) {
+	if m := personRx.FindStringSubmatch(person); m != nil {
+		add(source, VarAuthorName, m[1])
+		add(source, VarAuthorEmail, m[2])
+	}
+}
+
+// addAuthors adds the first author of a TOML authors array, given either as
+// strings ("Jane <jane@example.com>") or inline tables ({name = "Jane"})
+func addAuthors(add addFunc, source, authors string) {
+	if strings.Contains(authors, "{") {
+		for _, m := range inlineRx.FindAllStringSubmatch(authors, -1) {
+			switch m[1] {
+			case "name":
+				add(source, VarAuthorName, m[2])
+			case "email":
+				add(source, VarAuthorEmail, m[2])
+			}
+		}
+		return
+	}
+	if author := unquote(authors); author != "" {
+		addPerson(add, source, author)
+	}
+}
+
+// xmlElement returns the text of the first element with the given name
+func xmlElement(data []byte, name string) string {
+	rx := regexp.MustCompile(`<` + name + `>\s*([^<]*?)\s*</` + name + `>`)
+	if m := rx.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// readGitConfig returns the key values of each section of a git config file
+func readGitConfig(path string) map[string]map[string]string {
+	sections := spec.ReadSections(path)
+	for _, values := range sections {
+		for key, value := range values {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+	return sections
+}
+
+// unquote returns the first quoted string of a TOML value
+func unquote(value string) string {
+	m := quotedRx.FindStringSubmatch(value)
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+func readJSON(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest map[string]any
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
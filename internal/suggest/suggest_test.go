@@ -0,0 +1,108 @@
+package suggest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+// writeTree writes files to a temporary source tree and returns its path
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var manifests = map[string]string{
+	"go.mod": "module github.com/acme/widget\n\ngo 1.22\n",
+	"pyproject.toml": "[project]\n" +
+		"name = \"widget\"\n" +
+		"description = \"Widgets for everyone\"\n" +
+		"version = \"0.3.0\"\n" +
+		"authors = [{name = \"Jane Doe\", email = \"jane@acme.dev\"}]\n",
+	"package.json": `{"name": "@acme/widget-ui", "version": "0.3.0", "author": "Jane Doe <jane@acme.dev>"}`,
+	"main.go": "package main\n\n" +
+		"import \"github.com/acme/widget/internal/cli\"\n\n" +
+		"// widget entry point\n" +
+		"func main() { cli.Run(\"widget\") }\n",
+}
+
+func TestScan(t *testing.T) {
+	got := Scan(writeTree(t, manifests))
+	want := []Candidate{
+		{VarProjectName, "Project name", "widget", []string{"go.mod", "pyproject.toml"}},
+		{VarProjectName + "_2", "Project name", "widget-ui", []string{"package.json"}},
+		{VarOrg, "Organization or namespace", "acme", []string{"go.mod", "package.json"}},
+		{VarAuthorName, "Author name", "Jane Doe", []string{"package.json", "pyproject.toml"}},
+		{VarAuthorEmail, "Author email", "jane@acme.dev", []string{"package.json", "pyproject.toml"}},
+		{VarDescription, "Project description", "Widgets for everyone", []string{"pyproject.toml"}},
+		{VarVersion, "Initial version", "0.3.0", []string{"package.json", "pyproject.toml"}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b Candidate) bool {
+		return a.Variable == b.Variable && a.Description == b.Description && a.Value == b.Value && slices.Equal(a.Sources, b.Sources)
+	}) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCountAndRender(t *testing.T) {
+	dir := writeTree(t, manifests)
+	suggestions, err := Count(dir, &spec.Spec{}, Scan(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byVariable := make(map[string]Suggestion)
+	for _, s := range suggestions {
+		byVariable[s.Variable] = s
+	}
+	name, ok := byVariable[VarProjectName]
+	if !ok {
+		t.Fatalf("no %s suggestion in %+v", VarProjectName, suggestions)
+	}
+	// main.go holds widget in the import path, the comment and the string
+	if cats := name.NodeTypes(); !slices.Contains(cats, "namespace") || !slices.Contains(cats, "comment") || !slices.Contains(cats, "string") {
+		t.Errorf("%s node types = %v, want namespace, comment and string", VarProjectName, cats)
+	}
+
+	out, err := Render(suggestions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Suggested by mintmpl suggest, review before use\nvariables:\n",
+		"  project_name:\n    type: str\n    description: Project name\n    default: widget\n",
+		"  org:\n    type: str\n    description: Organization or namespace\n    default: acme\n",
+		"      - match: widget\n        node_types: [",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("snippet lacks %q:\n%s", want, out)
+		}
+	}
+
+	// the snippet loads as a spec declaring the suggested variables
+	specPath := filepath.Join(t.TempDir(), ".mintmpl.yml")
+	if err := os.WriteFile(specPath, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := spec.Load(specPath)
+	if err != nil {
+		t.Fatalf("loading snippet: %v", err)
+	}
+	if v := s.Variables[VarOrg]; v == nil || v.Default != "acme" || len(v.Transforms) != 1 || v.Transforms[0].Match != "acme" {
+		t.Errorf("org variable = %+v, want a transform matching acme", v)
+	}
+}
@@ -0,0 +1,178 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+var categoryFixtures = map[string]string{
+	"main.tf": `# acme infra
+resource "aws_s3_bucket" "acme" {
+  bucket = "acme-data"
+  tags = { Owner = var.acme_owner }
+}
+`,
+	"Dockerfile": `# build acme
+FROM golang:1.22 AS acme-build
+ARG ACME_VERSION=1.0
+ENV ACME_HOME=/opt/acme
+LABEL org.acme.name="acme"
+RUN go build -o /bin/acme ./cmd/acme
+`,
+	"deploy.sh": `#!/bin/bash
+# deploy acme
+acme_deploy() {
+  local ACME_ENV='prod'
+  echo "deploying acme to $ACME_ENV"
+}
+`,
+	"index.php":        "<h1>acme</h1>\n<?php\n// acme home\n$acme = \"acme\";\n",
+	"init.lua":         "-- acme setup\nlocal acme = require(\"acme\")\n",
+	"acme.proto":       "// acme api\npackage acme.v1;\noption go_package = \"example.com/acme\";\n",
+	"schema.graphql":   "# acme schema\ntype AcmeUser {\n  acmeId: ID! @deprecated(reason: \"use acme\")\n}\n",
+	"init.sql":         "-- acme schema\nCREATE SCHEMA acme;\nSELECT acme_id FROM acme.users WHERE name = 'acme';\n",
+	"app.properties":   "# acme settings\nacme.name=acme-service\n",
+	".env.example":     "# acme env\nACME_URL=https://acme.example.com\n",
+	"application.conf": "# acme config\nacme {\n  name = \"acme\"\n  host = acme.local\n}\n",
+	"Makefile":         "# acme targets\nACME_BIN := bin/acme\nacme: deps\n\tgo build -o $(ACME_BIN) ./cmd/acme\n",
+}
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		path string
+		cat  languages.NodeCategory
+		want string
+	}{
+		{"main.tf", languages.CategoryString, `# acme infra
+resource "aws_s3_bucket" "{{ project_name }}" {
+  bucket = "{{ project_name }}-data"
+  tags = { Owner = var.acme_owner }
+}
+`},
+		{"main.tf", languages.CategoryIdentifier, `# acme infra
+resource "aws_s3_bucket" "{{ project_name }}" {
+  bucket = "acme-data"
+  tags = { Owner = var.{{ project_name }}_owner }
+}
+`},
+		{"main.tf", languages.CategoryComment, `# {{ project_name }} infra
+resource "aws_s3_bucket" "acme" {
+  bucket = "acme-data"
+  tags = { Owner = var.acme_owner }
+}
+`},
+		// block labels only, not the strings in the body
+		{"main.tf", "hcl:block.string_lit", `# acme infra
+resource "aws_s3_bucket" "{{ project_name }}" {
+  bucket = "acme-data"
+  tags = { Owner = var.acme_owner }
+}
+`},
+
+		{"Dockerfile", languages.CategoryString, `# build acme
+FROM golang:1.22 AS acme-build
+ARG ACME_VERSION=1.0
+ENV ACME_HOME=/opt/{{ project_name }}
+LABEL org.acme.name="{{ project_name }}"
+RUN go build -o /bin/{{ project_name }} ./cmd/{{ project_name }}
+`},
+		{"Dockerfile", languages.CategoryIdentifier, `# build acme
+FROM golang:1.22 AS {{ project_name }}-build
+ARG ACME_VERSION=1.0
+ENV ACME_HOME=/opt/acme
+LABEL org.{{ project_name }}.name="acme"
+RUN go build -o /bin/acme ./cmd/acme
+`},
+		{"Dockerfile", languages.CategoryComment, `# build {{ project_name }}
+FROM golang:1.22 AS acme-build
+ARG ACME_VERSION=1.0
+ENV ACME_HOME=/opt/acme
+LABEL org.acme.name="acme"
+RUN go build -o /bin/acme ./cmd/acme
+`},
+		{"Dockerfile", "dockerfile:env_pair.value", `# build acme
+FROM golang:1.22 AS acme-build
+ARG ACME_VERSION=1.0
+ENV ACME_HOME=/opt/{{ project_name }}
+LABEL org.acme.name="acme"
+RUN go build -o /bin/acme ./cmd/acme
+`},
+
+		{"deploy.sh", languages.CategoryString, `#!/bin/bash
+# deploy acme
+acme_deploy() {
+  local ACME_ENV='prod'
+  echo "deploying {{ project_name }} to $ACME_ENV"
+}
+`},
+		{"deploy.sh", languages.CategoryIdentifier, `#!/bin/bash
+# deploy acme
+{{ project_name }}_deploy() {
+  local ACME_ENV='prod'
+  echo "deploying acme to $ACME_ENV"
+}
+`},
+		{"deploy.sh", languages.CategoryComment, `#!/bin/bash
+# deploy {{ project_name }}
+acme_deploy() {
+  local ACME_ENV='prod'
+  echo "deploying acme to $ACME_ENV"
+}
+`},
+		{"deploy.sh", "bash:function_definition.name", `#!/bin/bash
+# deploy acme
+{{ project_name }}_deploy() {
+  local ACME_ENV='prod'
+  echo "deploying acme to $ACME_ENV"
+}
+`},
+
+		{"Makefile", languages.CategoryString, "# acme targets\nACME_BIN := bin/{{ project_name }}\nacme: deps\n\tgo build -o $(ACME_BIN) ./cmd/{{ project_name }}\n"},
+		{"Makefile", languages.CategoryIdentifier, "# acme targets\nACME_BIN := bin/acme\n{{ project_name }}: deps\n\tgo build -o $(ACME_BIN) ./cmd/acme\n"},
+		{"Makefile", languages.CategoryComment, "# {{ project_name }} targets\nACME_BIN := bin/acme\nacme: deps\n\tgo build -o $(ACME_BIN) ./cmd/acme\n"},
+		{"Makefile", "make:targets.word", "# acme targets\nACME_BIN := bin/acme\n{{ project_name }}: deps\n\tgo build -o $(ACME_BIN) ./cmd/acme\n"},
+
+		{"index.php", languages.CategoryString, "<h1>acme</h1>\n<?php\n// acme home\n$acme = \"{{ project_name }}\";\n"},
+		{"index.php", languages.CategoryIdentifier, "<h1>acme</h1>\n<?php\n// acme home\n${{ project_name }} = \"acme\";\n"},
+		{"index.php", "html", "<h1>{{ project_name }}</h1>\n<?php\n// acme home\n$acme = \"acme\";\n"},
+
+		{"init.lua", languages.CategoryString, "-- acme setup\nlocal acme = require(\"{{ project_name }}\")\n"},
+		{"init.lua", languages.CategoryIdentifier, "-- acme setup\nlocal {{ project_name }} = require(\"acme\")\n"},
+		{"init.lua", languages.CategoryComment, "-- {{ project_name }} setup\nlocal acme = require(\"acme\")\n"},
+
+		{"acme.proto", languages.CategoryString, "// acme api\npackage acme.v1;\noption go_package = \"example.com/{{ project_name }}\";\n"},
+		{"acme.proto", languages.CategoryNamespace, "// acme api\npackage {{ project_name }}.v1;\noption go_package = \"example.com/acme\";\n"},
+		{"acme.proto", languages.CategoryComment, "// {{ project_name }} api\npackage acme.v1;\noption go_package = \"example.com/acme\";\n"},
+
+		{"schema.graphql", languages.CategoryString, "# acme schema\ntype AcmeUser {\n  acmeId: ID! @deprecated(reason: \"use {{ project_name }}\")\n}\n"},
+		{"schema.graphql", languages.CategoryIdentifier, "# acme schema\ntype AcmeUser {\n  {{ project_name }}Id: ID! @deprecated(reason: \"use acme\")\n}\n"},
+
+		{"init.sql", languages.CategoryString, "-- acme schema\nCREATE SCHEMA acme;\nSELECT acme_id FROM acme.users WHERE name = '{{ project_name }}';\n"},
+		{"init.sql", languages.CategoryNamespace, "-- acme schema\nCREATE SCHEMA {{ project_name }};\nSELECT acme_id FROM {{ project_name }}.users WHERE name = 'acme';\n"},
+
+		{"app.properties", languages.CategoryString, "# acme settings\nacme.name={{ project_name }}-service\n"},
+		{"app.properties", languages.CategoryIdentifier, "# acme settings\n{{ project_name }}.name=acme-service\n"},
+
+		{".env.example", languages.CategoryString, "# acme env\nACME_URL=https://{{ project_name }}.example.com\n"},
+		{".env.example", languages.CategoryComment, "# {{ project_name }} env\nACME_URL=https://acme.example.com\n"},
+
+		{"application.conf", languages.CategoryString, "# acme config\nacme {\n  name = \"{{ project_name }}\"\n  host = {{ project_name }}.local\n}\n"},
+		{"application.conf", languages.CategoryIdentifier, "# acme config\n{{ project_name }} {\n  name = \"acme\"\n  host = acme.local\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"/"+string(tt.cat), func(t *testing.T) {
+			trans := New([]spec.Transform{{
+				Match:         "acme",
+				Replace:       "{{ project_name }}",
+				NodeTypes:     []languages.NodeCategory{tt.cat},
+				CaseSensitive: true,
+			}}, nil)
+			got, _ := trans.TransformFile(tt.path, []byte(categoryFixtures[tt.path]))
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
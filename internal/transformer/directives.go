@@ -0,0 +1,252 @@
+package transformer
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/alexaandru/go-tree-sitter-bare"
+	"github.com/tnaucoin/mintmpl/internal/languages"
+)
+
+const directivePrefix = "mintmpl:"
+
+// Directives recognized inside comments
+const (
+	directiveIgnoreNextLine = "ignore-next-line"
+	directiveIgnoreStart    = "ignore-start"
+	directiveIgnoreEnd      = "ignore-end"
+	directiveVar            = "var"
+)
+
+type span struct {
+	start, end uint32
+}
+
+func (s span) overlaps(r Replacement) bool {
+	return r.StartByte < s.end && s.start < r.EndByte
+}
+
+// directives holds what the inline comments of a single file asked for.
+type directives struct {
+	ignored []span
+	strips  []Replacement
+	vars    []varDirective
+}
+
+type varDirective struct {
+	Replacement
+	name string
+}
+
+type pendingVar struct {
+	after, before uint32
+	expr          string
+}
+
+// collectDirectives scans comment nodes for mintmpl directives.
+func (t *Transformer) collectDirectives(root *sitter.Node, source []byte, langConfig *languages.LanguageConfig) directives {
+	var d directives
+	var comments, literals []*sitter.Node
+	walkDirectiveNodes(root, langConfig, &comments, &literals)
+
+	var pending []pendingVar
+	ignoreStart := -1
+	for _, c := range comments {
+		text := string(source[c.StartByte():c.EndByte()])
+		name, args, ok := parseDirective(text)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case directiveIgnoreNextLine:
+			_, lineEnd := lineBounds(source, uint32(c.EndByte()))
+			_, nextEnd := lineBounds(source, lineEnd)
+			d.ignored = append(d.ignored, span{start: lineEnd, end: nextEnd})
+		case directiveIgnoreStart:
+			if ignoreStart == -1 {
+				ignoreStart = int(c.EndByte())
+			}
+		case directiveIgnoreEnd:
+			if ignoreStart != -1 {
+				d.ignored = append(d.ignored, span{start: uint32(ignoreStart), end: uint32(c.StartByte())})
+				ignoreStart = -1
+			}
+		case directiveVar:
+			if args == "" {
+				continue
+			}
+			// the literal must start on the comment's line or the next one
+			_, lineEnd := lineBounds(source, uint32(c.EndByte()))
+			_, nextEnd := lineBounds(source, lineEnd)
+			pending = append(pending, pendingVar{after: uint32(c.EndByte()), before: nextEnd, expr: args})
+		default:
+			continue
+		}
+
+		d.strips = append(d.strips, stripComment(source, uint32(c.StartByte()), uint32(c.EndByte())))
+	}
+	if ignoreStart != -1 {
+		d.ignored = append(d.ignored, span{start: uint32(ignoreStart), end: uint32(len(source))})
+	}
+
+	for _, p := range pending {
+		for _, lit := range literals {
+			if uint32(lit.StartByte()) < p.after {
+				continue
+			}
+			if uint32(lit.StartByte()) < p.before {
+				d.vars = append(d.vars, varReplacement(source, lit, p.expr))
+			}
+			break
+		}
+	}
+	return d
+}
+
+// walkDirectiveNodes collects comment nodes and outermost string nodes in
+// document order.
+func walkDirectiveNodes(node *sitter.Node, langConfig *languages.LanguageConfig, comments, literals *[]*sitter.Node) {
+	switch langConfig.GetNodeCategory(node.Type()) {
+	case languages.CategoryComment:
+		n := *node
+		*comments = append(*comments, &n)
+		return
+	case languages.CategoryString:
+		n := *node
+		*literals = append(*literals, &n)
+		return
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(uint32(i))
+		walkDirectiveNodes(&child, langConfig, comments, literals)
+	}
+}
+
+// parseDirective extracts the directive name and its arguments from a comment.
+func parseDirective(comment string) (string, string, bool) {
+	idx := strings.Index(comment, directivePrefix)
+	if idx == -1 {
+		return "", "", false
+	}
+	rest := comment[idx+len(directivePrefix):]
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSuffix(rest, "*/")
+	rest = strings.TrimSuffix(rest, "-->")
+	rest = strings.TrimSpace(rest)
+
+	name, args, _ := strings.Cut(rest, " ")
+	return name, strings.TrimSpace(args), name != ""
+}
+
+// varReplacement replaces the contents of a literal, keeping its quotes.
+func varReplacement(source []byte, lit *sitter.Node, expr string) varDirective {
+	start, end := uint32(lit.StartByte()), uint32(lit.EndByte())
+	for end-start >= 2 && isQuote(source[start]) && source[end-1] == source[start] {
+		start++
+		end--
+	}
+
+	name, _, _ := strings.Cut(expr, "|")
+	return varDirective{
+		Replacement: Replacement{
+			StartByte: start,
+			EndByte:   end,
+			OldText:   string(source[start:end]),
+			NewText:   fmt.Sprintf("{{ %s }}", expr),
+		},
+		name: strings.TrimSpace(name),
+	}
+}
+
+func isQuote(b byte) bool {
+	return b == '"' || b == '\'' || b == '`'
+}
+
+// stripComment removes a directive comment, taking its whole line with it
+// when the comment sits on a line of its own, or else the blanks separating
+// it from the code.
+func stripComment(source []byte, start, end uint32) Replacement {
+	lineStart, _ := lineBounds(source, start)
+	_, lineEnd := lineBounds(source, end)
+	leading := strings.TrimSpace(string(source[lineStart:start])) == ""
+	switch {
+	case leading && strings.TrimSpace(string(source[end:lineEnd])) == "":
+		start, end = lineStart, lineEnd
+	case leading:
+		// code follows the comment; take the blanks after it
+		for end < lineEnd && (source[end] == ' ' || source[end] == '\t') {
+			end++
+		}
+	default:
+		for start > lineStart && (source[start-1] == ' ' || source[start-1] == '\t') {
+			start--
+		}
+	}
+	return Replacement{
+		StartByte: start,
+		EndByte:   end,
+		OldText:   string(source[start:end]),
+		NewText:   "",
+	}
+}
+
+// mergeDirectives combines spec replacements with the directives. Ignored
+// spans drop every replacement touching them, and spec replacements win over
+// overlapping var directives.
+func (t *Transformer) mergeDirectives(d directives, replacements []Replacement) []Replacement {
+	if len(d.ignored) == 0 && len(d.strips) == 0 && len(d.vars) == 0 {
+		return replacements
+	}
+
+	var merged []Replacement
+	for _, r := range replacements {
+		if !d.suppressed(r, true) {
+			merged = append(merged, r)
+		}
+	}
+	specCount := len(merged)
+
+	for _, v := range d.vars {
+		if d.suppressed(v.Replacement, false) {
+			continue
+		}
+		conflict := false
+		for _, r := range merged[:specCount] {
+			if r.StartByte < v.EndByte && v.StartByte < r.EndByte {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			merged = append(merged, v.Replacement)
+			if _, ok := t.directiveVars[v.name]; !ok {
+				t.directiveVars[v.name] = v.OldText
+			}
+		}
+	}
+
+	return append(merged, d.strips...)
+}
+
+func (d directives) suppressed(r Replacement, checkStrips bool) bool {
+	for _, s := range d.ignored {
+		if s.overlaps(r) {
+			return true
+		}
+	}
+	if checkStrips {
+		for _, s := range d.strips {
+			if r.StartByte < s.EndByte && s.StartByte < r.EndByte {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DirectiveVariables returns the variables named by `mintmpl:var` directives
+// along with the literal each one replaced, for use as a default.
+func (t *Transformer) DirectiveVariables() map[string]string {
+	return t.directiveVars
+}
@@ -0,0 +1,110 @@
+package transformer
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+var acmeString = spec.Transform{
+	Match:         "acme",
+	Replace:       "{{ project_name }}",
+	NodeTypes:     []languages.NodeCategory{languages.CategoryString},
+	CaseSensitive: true,
+}
+
+func TestDirectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+		vars   map[string]string
+	}{
+		{
+			name: "ignore next line",
+			source: "package main\n\n" +
+				"// mintmpl:ignore-next-line\n" +
+				"var a = \"acme\"\n" +
+				"var b = \"acme\"\n",
+			want: "package main\n\n" +
+				"var a = \"acme\"\n" +
+				"var b = \"{{ project_name }}\"\n",
+		},
+		{
+			name: "ignore block",
+			source: "package main\n\n" +
+				"// mintmpl:ignore-start\n" +
+				"var a = \"acme\"\n" +
+				"var b = \"acme\"\n" +
+				"// mintmpl:ignore-end\n" +
+				"var c = \"acme\"\n",
+			want: "package main\n\n" +
+				"var a = \"acme\"\n" +
+				"var b = \"acme\"\n" +
+				"var c = \"{{ project_name }}\"\n",
+		},
+		{
+			name: "var on the next literal",
+			source: "package main\n\n" +
+				"// mintmpl:var service_name\n" +
+				"const name = \"billing\"\n",
+			want: "package main\n\n" +
+				"const name = \"{{ service_name }}\"\n",
+			vars: map[string]string{"service_name": "billing"},
+		},
+		{
+			name: "var with a filter",
+			source: "package main\n\n" +
+				"/* mintmpl:var port | default(8080) */ const port = \"8080\"\n",
+			want: "package main\n\n" +
+				"const port = \"{{ port | default(8080) }}\"\n",
+			vars: map[string]string{"port": "8080"},
+		},
+		{
+			// the spec wins over a directive targeting the same literal
+			name: "spec wins",
+			source: "package main\n\n" +
+				"// mintmpl:var service_name\n" +
+				"const name = \"acme\"\n",
+			want: "package main\n\n" +
+				"const name = \"{{ project_name }}\"\n",
+			vars: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trans := New([]spec.Transform{acmeString}, nil)
+			got, _ := trans.TransformFile("main.go", []byte(tt.source))
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if tt.vars != nil && !maps.Equal(trans.DirectiveVariables(), tt.vars) {
+				t.Errorf("directive variables %v, want %v", trans.DirectiveVariables(), tt.vars)
+			}
+		})
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		source, comment, want string
+	}{
+		// a comment on its own line takes the line with it
+		{"a\n  // mintmpl:ignore-start\nb\n", "// mintmpl:ignore-start", "a\nb\n"},
+		// a trailing comment takes the blanks before it
+		{"x := 1 // mintmpl:ignore-next-line\n", "// mintmpl:ignore-next-line", "x := 1\n"},
+		// a leading comment takes the blanks after it
+		{"\t/* mintmpl:var port */ port := 1\n", "/* mintmpl:var port */", "\tport := 1\n"},
+	}
+	for _, tt := range tests {
+		start := uint32(strings.Index(tt.source, tt.comment))
+		r := stripComment([]byte(tt.source), start, start+uint32(len(tt.comment)))
+		got := tt.source[:r.StartByte] + r.NewText + tt.source[r.EndByte:]
+		if got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package transformer
+
+import (
+	"context"
+	"strings"
+
+	sitter "github.com/alexaandru/go-tree-sitter-bare"
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+// maxInjectionDepth bounds how deeply injected code is searched for further
+// injections
+const maxInjectionDepth = 3
+
+// collectInjected re-parses code embedded in the host tree with its own
+// grammar. It returns replacements for it in host offsets along with the spans
+// handled this way, which host transforms should leave alone. Embedded code
+// without any replacement stays with the host.
+func (t *Transformer) collectInjected(node *sitter.Node, source []byte, langConfig *languages.LanguageConfig, path string, depth int) ([]Replacement, []span) {
+	if len(langConfig.Injections) == 0 || depth > maxInjectionDepth {
+		return nil, nil
+	}
+
+	for _, inj := range langConfig.Injections {
+		if inj.NodeType != node.Type() {
+			continue
+		}
+		if len(inj.Paths) > 0 && !spec.MatchPath(path, inj.Paths) {
+			continue
+		}
+		start, end, langName, ok := injectedCode(node, source, inj)
+		if !ok {
+			continue
+		}
+		inner := t.lookup(langName)
+		if inner == nil || inner.Language == nil {
+			continue
+		}
+
+		replacements := t.transformInjected(source[start:end], inner, path, depth)
+		if len(replacements) == 0 {
+			// nothing matched in the inner grammar, such as bare words in a
+			// shell script; leave the code to the host transforms
+			return nil, nil
+		}
+		for i := range replacements {
+			replacements[i].StartByte += start
+			replacements[i].EndByte += start
+		}
+		return replacements, []span{{start: start, end: end}}
+	}
+
+	var replacements []Replacement
+	var spans []span
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(uint32(i))
+		r, s := t.collectInjected(&child, source, langConfig, path, depth)
+		replacements = append(replacements, r...)
+		spans = append(spans, s...)
+	}
+	return replacements, spans
+}
+
+// transformInjected collects replacements for embedded code, relative to the
+// start of the code. Transforms are scoped to the embedded language.
+func (t *Transformer) transformInjected(code []byte, langConfig *languages.LanguageConfig, path string, depth int) []Replacement {
+	scoped := t.transformsFor(path, langConfig.Name)
+
+	tree, err := t.getParser(langConfig).ParseString(context.Background(), nil, code)
+	if err != nil {
+		return nil
+	}
+	root := tree.RootNode()
+	replacements := t.collectReplacements(&root, code, langConfig, scoped, false)
+	injected, spans := t.collectInjected(&root, code, langConfig, path, depth+1)
+	return append(withoutSpans(replacements, spans), injected...)
+}
+
+// injectedCode locates the embedded code of an injection within a host node
+// and the language it is written in.
+func injectedCode(node *sitter.Node, source []byte, inj languages.Injection) (uint32, uint32, string, bool) {
+	langName := inj.Language
+	content := *node
+
+	if inj.Key != "" {
+		key := node.ChildByFieldName("key")
+		value := node.ChildByFieldName("value")
+		if key.IsNull() || value.IsNull() || trimQuotes(key.Content(source)) != inj.Key {
+			return 0, 0, "", false
+		}
+		content = value
+	}
+
+	if inj.LanguageNode != "" {
+		if langNode, ok := findChild(node, inj.LanguageNode); ok {
+			langName = langNode.Content(source)
+		}
+	}
+	if inj.LanguageAttribute != "" {
+		if value, ok := attributeValue(node, source, inj.LanguageAttribute); ok {
+			langName = value
+		}
+	}
+	if inj.ContentNode != "" {
+		contentNode, ok := findChild(node, inj.ContentNode)
+		if !ok {
+			return 0, 0, "", false
+		}
+		content = contentNode
+	}
+	if langName == "" {
+		return 0, 0, "", false
+	}
+
+	start, end := uint32(content.StartByte()), uint32(content.EndByte())
+	text := source[start:end]
+	switch {
+	case len(text) > 0 && (text[0] == '|' || text[0] == '>'):
+		// block scalar; the code starts after the header line
+		idx := strings.IndexByte(string(text), '\n')
+		if idx == -1 {
+			return 0, 0, "", false
+		}
+		start += uint32(idx) + 1
+	case len(text) >= 2 && isQuote(text[0]) && text[len(text)-1] == text[0]:
+		start++
+		end--
+	}
+
+	if inj.Contains != "" && !strings.Contains(string(source[start:end]), inj.Contains) {
+		return 0, 0, "", false
+	}
+	return start, end, langName, start < end
+}
+
+// findChild returns the first descendant of node with the given type
+func findChild(node *sitter.Node, nodeType string) (sitter.Node, bool) {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(uint32(i))
+		if child.Type() == nodeType {
+			return child, true
+		}
+		if found, ok := findChild(&child, nodeType); ok {
+			return found, true
+		}
+	}
+	return sitter.Node{}, false
+}
+
+// attributeValue returns the value of an attribute on the start tag of an
+// HTML-like element
+func attributeValue(node *sitter.Node, source []byte, name string) (string, bool) {
+	tag, ok := findChild(node, "start_tag")
+	if !ok {
+		return "", false
+	}
+	for i := 0; i < int(tag.ChildCount()); i++ {
+		attr := tag.Child(uint32(i))
+		if attr.Type() != "attribute" {
+			continue
+		}
+		attrName, ok := findChild(&attr, "attribute_name")
+		if !ok || attrName.Content(source) != name {
+			continue
+		}
+		if value, ok := findChild(&attr, "attribute_value"); ok {
+			return value.Content(source), true
+		}
+	}
+	return "", false
+}
+
+func trimQuotes(s string) string {
+	if len(s) >= 2 && isQuote(s[0]) && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// withoutSpans drops replacements overlapping any of the spans
+func withoutSpans(replacements []Replacement, spans []span) []Replacement {
+	if len(spans) == 0 {
+		return replacements
+	}
+	var kept []Replacement
+	for _, r := range replacements {
+		overlaps := false
+		for _, s := range spans {
+			if s.overlaps(r) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,73 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+func TestInjections(t *testing.T) {
+	tests := []struct {
+		name, path, source, want string
+	}{
+		{
+			name: "fenced code",
+			path: "README.md",
+			source: "# Usage\n\n" +
+				"```python\n" +
+				"name = \"acme\"  # acme\n" +
+				"```\n",
+			want: "# Usage\n\n" +
+				"```python\n" +
+				"name = \"{{ project_name }}\"  # acme\n" +
+				"```\n",
+		},
+		{
+			// bare words in a run script have no string node; the block
+			// scalar as a whole is still a yaml string
+			name: "workflow run",
+			path: ".github/workflows/ci.yml",
+			source: "jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - run: |\n" +
+				"          echo acme\n" +
+				"          ./acme\n",
+			want: "jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - run: |\n" +
+				"          echo {{ project_name }}\n" +
+				"          ./{{ project_name }}\n",
+		},
+		{
+			// script blocks are parsed with the grammar their lang names
+			name: "vue script",
+			path: "App.vue",
+			source: "<script lang=\"ts\">\n" +
+				"const name: string = \"acme\";\n" +
+				"</script>\n",
+			want: "<script lang=\"ts\">\n" +
+				"const name: string = \"{{ project_name }}\";\n" +
+				"</script>\n",
+		},
+		{
+			name: "html style",
+			path: "index.html",
+			source: "<style>\n" +
+				".banner::after { content: \"acme\"; }\n" +
+				"</style>\n",
+			want: "<style>\n" +
+				".banner::after { content: \"{{ project_name }}\"; }\n" +
+				"</style>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := New([]spec.Transform{acmeString}, nil).TransformFile(tt.path, []byte(tt.source))
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
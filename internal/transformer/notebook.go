@@ -0,0 +1,172 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultKernelLanguage is used for code cells of notebooks whose metadata
+// doesn't name a language
+const defaultKernelLanguage = "python"
+
+// jinjaTagRx matches Jinja expressions and statements on a line
+var jinjaTagRx = regexp.MustCompile(`\{\{.*?\}\}|\{%.*?%\}`)
+
+// tagPlaceholder stands for the Jinja tag of the given index while the
+// notebook is JSON encoded. Private use characters are not escaped.
+const tagPlaceholder = "\ue000%d\ue001"
+
+// SetStripNotebookOutputs enables clearing the outputs and execution counts
+// of notebook code cells.
+func (t *Transformer) SetStripNotebookOutputs(enabled bool) {
+	t.stripOutputs = enabled
+}
+
+func isNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// transformNotebook transforms the cells of a Jupyter notebook. Code cells are
+// parsed with the kernel's grammar and markdown cells as markdown; outputs are
+// never transformed. Notebooks that aren't valid JSON are left untouched.
+func (t *Transformer) transformNotebook(path string, content []byte) ([]byte, bool) {
+	var notebook map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&notebook); err != nil {
+		return content, false
+	}
+	cells, _ := notebook["cells"].([]any)
+	kernel := kernelLanguage(notebook)
+
+	changed := false
+	var tags []string
+	for _, c := range cells {
+		cell, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var langName string
+		switch cell["cell_type"] {
+		case "code":
+			langName = kernel
+			if t.stripOutputs {
+				if outputs, _ := cell["outputs"].([]any); len(outputs) > 0 || cell["execution_count"] != nil {
+					changed = true
+				}
+				cell["outputs"] = []any{}
+				cell["execution_count"] = nil
+			}
+		case "markdown":
+			langName = "markdown"
+		default:
+			continue
+		}
+
+		if t.transformCell(cell, langName, path, &tags) {
+			changed = true
+		}
+	}
+	if !changed {
+		return content, false
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(notebook); err != nil {
+		return content, false
+	}
+	return restoreTags(buf.Bytes(), tags), true
+}
+
+// transformCell transforms the source of a cell in place. Jinja tags the
+// transform introduced are replaced by placeholders and appended to tags, so
+// they are written unescaped, leaving existing text as it was.
+func (t *Transformer) transformCell(cell map[string]any, langName, path string, tags *[]string) bool {
+	langConfig := t.lookup(langName)
+	if langConfig == nil {
+		return false
+	}
+
+	var source string
+	lines, isList := cell["source"].([]any)
+	if isList {
+		var sb strings.Builder
+		for _, line := range lines {
+			s, _ := line.(string)
+			sb.WriteString(s)
+		}
+		source = sb.String()
+	} else if s, ok := cell["source"].(string); ok {
+		source = s
+	}
+	if source == "" {
+		return false
+	}
+
+	transforms := t.transformsFor(path, langConfig.Name)
+	var result []byte
+	var changed bool
+	if langConfig.Language == nil {
+		result, changed = t.transformPlaintext([]byte(source), transforms)
+	} else {
+		result, changed = t.transform([]byte(source), langConfig, transforms, path)
+	}
+	if !changed {
+		return false
+	}
+	result = jinjaTagRx.ReplaceAllFunc(result, func(tag []byte) []byte {
+		if strings.Contains(source, string(tag)) {
+			return tag
+		}
+		*tags = append(*tags, string(tag))
+		return fmt.Appendf(nil, tagPlaceholder, len(*tags)-1)
+	})
+
+	if !isList {
+		cell["source"] = string(result)
+		return true
+	}
+	// keep nbformat's one-string-per-line layout
+	var newLines []any
+	for _, line := range strings.SplitAfter(string(result), "\n") {
+		if line != "" {
+			newLines = append(newLines, line)
+		}
+	}
+	cell["source"] = newLines
+	return true
+}
+
+// kernelLanguage returns the language of a notebook's code cells
+func kernelLanguage(notebook map[string]any) string {
+	metadata, _ := notebook["metadata"].(map[string]any)
+	if kernelspec, ok := metadata["kernelspec"].(map[string]any); ok {
+		if name, ok := kernelspec["language"].(string); ok && name != "" {
+			return name
+		}
+	}
+	if info, ok := metadata["language_info"].(map[string]any); ok {
+		if name, ok := info["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return defaultKernelLanguage
+}
+
+// restoreTags puts the Jinja tags back in place of their placeholders without
+// JSON escaping, so that quoted arguments such as replace("-", "_") stay
+// valid Jinja in the template.
+func restoreTags(encoded []byte, tags []string) []byte {
+	for i, tag := range tags {
+		encoded = bytes.Replace(encoded, fmt.Appendf(nil, tagPlaceholder, i), []byte(tag), 1)
+	}
+	return encoded
+}
@@ -0,0 +1,81 @@
+package transformer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# acme"]},
+  {"cell_type": "code", "execution_count": 1, "metadata": {}, "source": ["name = \"acme\""],
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["{{ not ours }}\n", "\"acme\"\n"]}]}
+ ],
+ "metadata": {"kernelspec": {"language": "python"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`
+
+func notebookTransformer() *Transformer {
+	return New([]spec.Transform{{
+		Match:         "acme",
+		Replace:       "{{ project_name | replace(\"-\", \"_\") }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryString, languages.CategoryAny},
+		CaseSensitive: true,
+	}}, nil)
+}
+
+func TestTransformNotebook(t *testing.T) {
+	got, changed := notebookTransformer().TransformFile("demo.ipynb", []byte(testNotebook))
+	if !changed {
+		t.Fatal("notebook not transformed")
+	}
+	out := string(got)
+
+	// the title cell has no final newline
+	if !strings.Contains(out, `"# {{ project_name | replace("-", "_") }}"`) {
+		t.Errorf("title cell not transformed:\n%s", out)
+	}
+	if !strings.Contains(out, `"name = \"{{ project_name | replace("-", "_") }}\""`) {
+		t.Errorf("code cell not transformed:\n%s", out)
+	}
+	// outputs keep their text and JSON escaping
+	if !strings.Contains(out, `"{{ not ours }}\n"`) || !strings.Contains(out, `"\"acme\"\n"`) {
+		t.Errorf("outputs changed:\n%s", out)
+	}
+}
+
+func TestStripNotebookOutputs(t *testing.T) {
+	trans := New(nil, nil)
+	trans.SetStripNotebookOutputs(true)
+
+	stripped, changed := trans.TransformFile("demo.ipynb", []byte(testNotebook))
+	if !changed {
+		t.Fatal("outputs not stripped")
+	}
+	var notebook struct {
+		Cells []map[string]any `json:"cells"`
+	}
+	if err := json.Unmarshal(stripped, &notebook); err != nil {
+		t.Fatalf("stripped notebook is not valid JSON: %v", err)
+	}
+	if outputs := notebook.Cells[1]["outputs"].([]any); len(outputs) != 0 || notebook.Cells[1]["execution_count"] != nil {
+		t.Errorf("outputs kept: %v", notebook.Cells[1])
+	}
+
+	if _, changed := trans.TransformFile("demo.ipynb", stripped); changed {
+		t.Error("already stripped notebook reported as changed")
+	}
+}
+
+func TestMarkdownWithoutFinalNewline(t *testing.T) {
+	got, changed := notebookTransformer().TransformFile("README.md", []byte("# acme"))
+	if want := `# {{ project_name | replace("-", "_") }}`; !changed || string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package transformer
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	sitter "github.com/alexaandru/go-tree-sitter-bare"
+	"github.com/tnaucoin/mintmpl/internal/languages"
+)
+
+// Occurrence counts the nodes of one category containing a value
+type Occurrence struct {
+	Value    string
+	Language string
+	// Category is empty for files without a grammar, counted as plaintext
+	Category languages.NodeCategory
+	Count    int
+}
+
+// LanguageName returns the language a file is transformed as, or "" when it
+// is copied as is
+func (t *Transformer) LanguageName(path string, content []byte) string {
+	if lc := t.languageFor(path, content); lc != nil {
+		return lc.Name
+	}
+	return ""
+}
+
+// CountOccurrences counts where the values appear in a file. Each occurrence
+// is attributed to the innermost categorized node containing it, the node a
+// transform would have to target. Occurrences inside a longer value aren't
+// counted, as its transform claims them first.
+func (t *Transformer) CountOccurrences(path string, content []byte, values []string) []Occurrence {
+	langConfig := t.languageFor(path, content)
+	if langConfig == nil {
+		return nil
+	}
+
+	var occurrences []Occurrence
+	if langConfig.Language == nil {
+		for _, value := range values {
+			if n := countUnclaimed(string(content), value, values); n > 0 {
+				occurrences = append(occurrences, Occurrence{Value: value, Language: langConfig.Name, Count: n})
+			}
+		}
+		return occurrences
+	}
+
+	tree, err := t.getParser(langConfig).ParseString(context.Background(), nil, content)
+	if err != nil {
+		return nil
+	}
+	root := tree.RootNode()
+	for _, value := range values {
+		counts := make(map[languages.NodeCategory]int)
+		countNodes(&root, content, langConfig, value, values, counts)
+		for _, cat := range sortedCategories(counts) {
+			occurrences = append(occurrences, Occurrence{Value: value, Language: langConfig.Name, Category: cat, Count: counts[cat]})
+		}
+	}
+	return occurrences
+}
+
+// countNodes adds the categorized nodes containing value to counts and
+// reports whether any did, so enclosing nodes aren't counted again.
+func countNodes(node *sitter.Node, source []byte, langConfig *languages.LanguageConfig, value string, values []string, counts map[languages.NodeCategory]int) bool {
+	text := string(source[node.StartByte():node.EndByte()])
+	if !strings.Contains(text, value) {
+		return false
+	}
+
+	found := false
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(uint32(i))
+		if countNodes(&child, source, langConfig, value, values, counts) {
+			found = true
+		}
+	}
+	if found {
+		return true
+	}
+	if cat := langConfig.GetNodeCategory(node.Type()); cat != "" {
+		if n := countUnclaimed(text, value, values); n > 0 {
+			counts[cat] += n
+		}
+		return true
+	}
+	return false
+}
+
+// countUnclaimed counts the occurrences of value in text that aren't part of
+// an occurrence of a longer one of values
+func countUnclaimed(text, value string, values []string) int {
+	var claimed [][2]int
+	for _, other := range values {
+		if len(other) <= len(value) {
+			continue
+		}
+		for start := 0; ; {
+			idx := strings.Index(text[start:], other)
+			if idx == -1 {
+				break
+			}
+			claimed = append(claimed, [2]int{start + idx, start + idx + len(other)})
+			start += idx + len(other)
+		}
+	}
+
+	n := 0
+	for start := 0; ; {
+		idx := strings.Index(text[start:], value)
+		if idx == -1 {
+			return n
+		}
+		idx += start
+		if !slices.ContainsFunc(claimed, func(c [2]int) bool { return c[0] < idx+len(value) && idx < c[1] }) {
+			n++
+		}
+		start = idx + len(value)
+	}
+}
+
+func sortedCategories(counts map[languages.NodeCategory]int) []languages.NodeCategory {
+	cats := make([]languages.NodeCategory, 0, len(counts))
+	for cat := range counts {
+		cats = append(cats, cat)
+	}
+	slices.SortFunc(cats, func(a, b languages.NodeCategory) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	return cats
+}
@@ -0,0 +1,203 @@
+package transformer
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	sitter "github.com/alexaandru/go-tree-sitter-bare"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+const endForTag = "{% endfor %}"
+
+type region struct {
+	start, end uint32
+	repeat     spec.Repeat
+}
+
+// collectRegions finds the regions each repeat should loop over. Regions that
+// overlap an earlier region are dropped.
+func (t *Transformer) collectRegions(root *sitter.Node, source []byte) []region {
+	var regions []region
+	for _, r := range t.repeats {
+		if r.Match == "" {
+			continue
+		}
+		var found []region
+		if r.NodeType == "" || root == nil {
+			found = lineRegions(source, r)
+		} else {
+			found = nodeRegions(root, source, r)
+		}
+		for _, reg := range found {
+			if !overlapsAny(reg, regions) {
+				regions = append(regions, reg)
+			}
+		}
+	}
+	return regions
+}
+
+// nodeRegions returns the innermost nodes of the repeat's node type whose
+// text contains the match, widened to whole lines when they sit on their own.
+func nodeRegions(node *sitter.Node, source []byte, r spec.Repeat) []region {
+	text := source[node.StartByte():node.EndByte()]
+	if !bytes.Contains(text, []byte(r.Match)) {
+		return nil
+	}
+
+	var regions []region
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(uint32(i))
+		regions = append(regions, nodeRegions(&child, source, r)...)
+	}
+	if len(regions) > 0 || node.Type() != r.NodeType {
+		return regions
+	}
+
+	start, end := widenToLines(source, uint32(node.StartByte()), uint32(node.EndByte()))
+	return []region{{start: start, end: end, repeat: r}}
+}
+
+// lineRegions returns every line containing the repeat's match.
+func lineRegions(source []byte, r spec.Repeat) []region {
+	var regions []region
+	offset := 0
+	for offset < len(source) {
+		idx := bytes.Index(source[offset:], []byte(r.Match))
+		if idx == -1 {
+			break
+		}
+		start, end := lineBounds(source, uint32(offset+idx))
+		regions = append(regions, region{start: start, end: end, repeat: r})
+		offset = int(end)
+	}
+	return regions
+}
+
+func lineBounds(source []byte, pos uint32) (uint32, uint32) {
+	start := uint32(bytes.LastIndexByte(source[:pos], '\n') + 1)
+	end := uint32(len(source))
+	if idx := bytes.IndexByte(source[pos:], '\n'); idx != -1 {
+		end = pos + uint32(idx) + 1
+	}
+	return start, end
+}
+
+// widenToLines extends start/end to full lines when the node is only
+// surrounded by indentation and an optional trailing separator.
+func widenToLines(source []byte, start, end uint32) (uint32, uint32) {
+	lineStart, _ := lineBounds(source, start)
+	if strings.TrimSpace(string(source[lineStart:start])) != "" {
+		return start, end
+	}
+	_, lineEnd := lineBounds(source, end)
+	suffix := strings.TrimSpace(string(source[end:lineEnd]))
+	if suffix != "" && suffix != "," && suffix != ";" {
+		return start, end
+	}
+	return lineStart, lineEnd
+}
+
+func overlapsAny(reg region, regions []region) bool {
+	for _, other := range regions {
+		if reg.start < other.end && other.start < reg.end {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapRegions turns each region into a loop body. Replacements that fall
+// inside a region are applied to the body first; the region's item fields
+// are then substituted. Replacements straddling a region boundary are dropped.
+func wrapRegions(source []byte, regions []region, replacements []Replacement) []Replacement {
+	if len(regions) == 0 {
+		return replacements
+	}
+
+	var outside []Replacement
+	inside := make([][]Replacement, len(regions))
+	for _, r := range replacements {
+		placed := false
+		for i, reg := range regions {
+			if r.StartByte >= reg.start && r.EndByte <= reg.end {
+				inside[i] = append(inside[i], Replacement{
+					StartByte: r.StartByte - reg.start,
+					EndByte:   r.EndByte - reg.start,
+					OldText:   r.OldText,
+					NewText:   r.NewText,
+				})
+				placed = true
+				break
+			}
+			if r.StartByte < reg.end && reg.start < r.EndByte {
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			outside = append(outside, r)
+		}
+	}
+
+	for i, reg := range regions {
+		oldText := string(source[reg.start:reg.end])
+		body := string(applyReplacements([]byte(oldText), inside[i]))
+		outside = append(outside, Replacement{
+			StartByte: reg.start,
+			EndByte:   reg.end,
+			OldText:   oldText,
+			NewText:   reg.repeat.LoopTag + substituteFields(body, reg.repeat) + endForTag,
+		})
+	}
+	return outside
+}
+
+func substituteFields(body string, r spec.Repeat) string {
+	if len(r.Fields) == 0 {
+		return body
+	}
+	pairs := make([]string, 0, len(r.Fields)*2)
+	for _, f := range r.Fields {
+		pairs = append(pairs, f.Match, f.Replace)
+	}
+	return strings.NewReplacer(pairs...).Replace(body)
+}
+
+// applyReplacements applies non-overlapping replacements to source.
+func applyReplacements(source []byte, replacements []Replacement) []byte {
+	// sort by position reverse order for safe replace
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].StartByte > replacements[j].StartByte
+	})
+
+	// guard against braces of the source joining Jinja delimiters, as in
+	// Kotlin's "${acme}" becoming "${{{ x }}}"; checked before source changes
+	original := string(source)
+	texts := make([]string, len(replacements))
+	for i, r := range replacements {
+		texts[i] = guardBraces(r.NewText, byteAt(original, int(r.StartByte)-1), byteAt(original, int(r.EndByte)))
+	}
+
+	result := source
+	for i, r := range replacements {
+		result = append(result[:r.StartByte], append([]byte(texts[i]), result[r.EndByte:]...)...)
+	}
+	return result
+}
+
+// guardBraces pads a replacement starting with "{{" after a "{", or ending
+// with "}}" before a "}", with a space, so "${acme}" becomes
+// "${ {{ x }} }" and renders to "${ acme }". before and after are the bytes
+// around the replaced text, or 0 at the edges of the source.
+func guardBraces(text string, before, after byte) string {
+	if before == '{' && strings.HasPrefix(text, "{{") {
+		text = " " + text
+	}
+	if after == '}' && strings.HasSuffix(text, "}}") {
+		text += " "
+	}
+	return text
+}
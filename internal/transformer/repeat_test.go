@@ -0,0 +1,103 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+func TestRepeatRegions(t *testing.T) {
+	projectName := spec.Transform{
+		Match:         "acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryString},
+		CaseSensitive: true,
+	}
+	noun := spec.Transform{Match: "services", Replace: "{{ noun }}", CaseSensitive: true}
+	tests := []struct {
+		name   string
+		path   string
+		source string
+		repeat spec.Repeat
+		extra  []spec.Transform
+		want   string
+	}{
+		{
+			name: "node region",
+			path: "routes.py",
+			source: "ROUTES = [\n" +
+				"    route(\"/billing\", \"acme-billing\"),\n" +
+				"]\n",
+			repeat: spec.Repeat{
+				Match:    "/billing",
+				NodeType: "call",
+				LoopTag:  "{% for service in services %}",
+				Fields: []spec.Transform{
+					{Match: "billing", Replace: "{{ service }}", CaseSensitive: true},
+				},
+			},
+			want: "ROUTES = [\n" +
+				"{% for service in services %}    route(\"/{{ service }}\", \"{{ project_name }}-{{ service }}\"),\n{% endfor %}" +
+				"]\n",
+		},
+		{
+			name: "line region",
+			path: "main.go",
+			source: "package main\n\n" +
+				"const (\n" +
+				"\tBilling = \"billing\"\n" +
+				")\n",
+			repeat: spec.Repeat{
+				Match:   "Billing =",
+				LoopTag: "{% for item in services %}",
+				Fields: []spec.Transform{
+					{Match: "Billing", Replace: "{{ item.const }}", CaseSensitive: true},
+					{Match: "billing", Replace: "{{ item.name }}", CaseSensitive: true},
+				},
+			},
+			want: "package main\n\n" +
+				"const (\n" +
+				"{% for item in services %}\t{{ item.const }} = \"{{ item.name }}\"\n{% endfor %}" +
+				")\n",
+		},
+		{
+			// plaintext transforms run after the loop tags are inserted and
+			// must leave them alone, even when they match the variable name
+			name: "plaintext",
+			path: "services.txt",
+			source: "acme services\n" +
+				"- billing\n",
+			repeat: spec.Repeat{
+				Match:   "- billing",
+				LoopTag: "{% for item in services %}",
+				Fields: []spec.Transform{
+					{Match: "billing", Replace: "{{ item }}", CaseSensitive: true},
+				},
+			},
+			extra: []spec.Transform{noun},
+			want: "{{ project_name }} {{ noun }}\n" +
+				"{% for item in services %}- {{ item }}\n{% endfor %}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transforms := append([]spec.Transform{projectName}, tt.extra...)
+			got, changed := New(transforms, []spec.Repeat{tt.repeat}).TransformFile(tt.path, []byte(tt.source))
+			if !changed || string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatFieldsLongestFirst(t *testing.T) {
+	r := spec.Repeat{Fields: []spec.Transform{
+		{Match: "billing-api", Replace: "{{ item.api }}"},
+		{Match: "billing", Replace: "{{ item.name }}"},
+	}}
+	got := substituteFields("billing: billing-api", r)
+	if want := "{{ item.name }}: {{ item.api }}"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
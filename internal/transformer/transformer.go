@@ -1,9 +1,12 @@
 package transformer
 
 import (
+	"bytes"
 	"context"
+	"slices"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	sitter "github.com/alexaandru/go-tree-sitter-bare"
 	"github.com/tnaucoin/mintmpl/internal/languages"
@@ -18,17 +21,58 @@ type Replacement struct {
 }
 
 type Transformer struct {
-	transforms []spec.Transform
-	parsers    map[string]*sitter.Parser
+	transforms    []spec.Transform
+	repeats       []spec.Repeat
+	parsers       map[string]*sitter.Parser
+	directiveVars map[string]string
+	table         *languages.Table
+	autoLanguages bool
+	overrides     []languageOverride
+	stripOutputs  bool
 }
 
-func New(transforms []spec.Transform) *Transformer {
+type languageOverride struct {
+	pattern  string
+	language string
+}
+
+func New(transforms []spec.Transform, repeats []spec.Repeat) *Transformer {
 	return &Transformer{
-		transforms: transforms,
-		parsers:    make(map[string]*sitter.Parser),
+		transforms:    transforms,
+		repeats:       repeats,
+		parsers:       make(map[string]*sitter.Parser),
+		directiveVars: make(map[string]string),
+		table:         languages.Builtin(),
 	}
 }
 
+// SetLanguages sets the languages files are matched against, such as those
+// of a spec with its overrides.
+func (t *Transformer) SetLanguages(table *languages.Table) {
+	t.table = table
+}
+
+// SetAutoLanguages enables transforming files outside the language table,
+// either with a heuristically categorized grammar or as plaintext.
+func (t *Transformer) SetAutoLanguages(enabled bool) {
+	t.autoLanguages = enabled
+}
+
+// SetLanguageOverrides sets the languages forced on files matching path
+// globs. More specific (longer) patterns take precedence.
+func (t *Transformer) SetLanguageOverrides(overrides map[string]string) {
+	t.overrides = t.overrides[:0]
+	for pattern, language := range overrides {
+		t.overrides = append(t.overrides, languageOverride{pattern: pattern, language: language})
+	}
+	sort.Slice(t.overrides, func(i, j int) bool {
+		if len(t.overrides[i].pattern) != len(t.overrides[j].pattern) {
+			return len(t.overrides[i].pattern) > len(t.overrides[j].pattern)
+		}
+		return t.overrides[i].pattern < t.overrides[j].pattern
+	})
+}
+
 func (t *Transformer) getParser(lang *languages.LanguageConfig) *sitter.Parser {
 	if parser, ok := t.parsers[lang.Name]; ok {
 		return parser
@@ -41,91 +85,164 @@ func (t *Transformer) getParser(lang *languages.LanguageConfig) *sitter.Parser {
 
 // Transform transforms the source using AST replacements
 func (t *Transformer) Transform(source []byte, langConfig *languages.LanguageConfig) ([]byte, bool) {
+	return t.transform(source, langConfig, t.transforms, "")
+}
+
+func (t *Transformer) transform(source []byte, langConfig *languages.LanguageConfig, transforms []spec.Transform, path string) ([]byte, bool) {
+	// some grammars, such as markdown's, need the final newline to close the
+	// last node, which notebook cells and some files lack
+	if len(source) > 0 && source[len(source)-1] != '\n' {
+		result, changed := t.transform(append(slices.Clip(source), '\n'), langConfig, transforms, path)
+		if !changed {
+			return source, false
+		}
+		return bytes.TrimSuffix(result, []byte{'\n'}), true
+	}
+
 	parser := t.getParser(langConfig)
 	tree, err := parser.ParseString(context.Background(), nil, source)
 	if err != nil {
 		return source, false
 	}
 	rootNode := tree.RootNode()
-	replacements := t.collectReplacements(&rootNode, source, langConfig, false)
+	replacements := t.collectReplacements(&rootNode, source, langConfig, transforms, false)
+	injected, spans := t.collectInjected(&rootNode, source, langConfig, path, 0)
+	replacements = append(withoutSpans(replacements, spans), injected...)
+	replacements = t.mergeDirectives(t.collectDirectives(&rootNode, source, langConfig), replacements)
+	replacements = wrapRegions(source, t.collectRegions(&rootNode, source), replacements)
 	if len(replacements) == 0 {
 		return source, false
 	}
 
-	// sort by position reverse order for safe replace
-	sort.Slice(replacements, func(i, j int) bool {
-		return replacements[i].StartByte > replacements[j].StartByte
-	})
-
-	result := source
-	for _, r := range replacements {
-		result = append(result[:r.StartByte], append([]byte(r.NewText), result[r.EndByte:]...)...)
-	}
+	return applyReplacements(source, replacements), true
+}
 
-	return result, true
+func (t *Transformer) collectReplacements(node *sitter.Node, source []byte, langConfig *languages.LanguageConfig, transforms []spec.Transform, parentReplaced bool) []Replacement {
+	return t.collectNodeReplacements(node, source, langConfig, transforms, parentReplaced, nil)
 }
 
-func (t *Transformer) collectReplacements(node *sitter.Node, source []byte, langConfig *languages.LanguageConfig, parentReplaced bool) []Replacement {
+// collectNodeReplacements walks the tree collecting replacements. qualified
+// holds the node's "parent.field" and "parent.type" names, which categories
+// may list to target a node only in a given position.
+func (t *Transformer) collectNodeReplacements(node *sitter.Node, source []byte, langConfig *languages.LanguageConfig, transforms []spec.Transform, parentReplaced bool, qualified []string) []Replacement {
 	var replacements []Replacement
 	thisNodeReplaced := false
 
 	if !parentReplaced {
 		nodeType := node.Type()
 
-		for _, transform := range t.transforms {
-			if !langConfig.MatchesCategory(nodeType, transform.NodeTypes) {
-				continue
+		var matching []spec.Transform
+		for _, transform := range transforms {
+			if langConfig.MatchesCategory(nodeType, transform.NodeTypes) || matchesQualified(langConfig, qualified, transform.NodeTypes) {
+				matching = append(matching, transform)
 			}
+		}
+		if len(matching) > 0 {
 			nodeText := string(source[node.StartByte():node.EndByte()])
-
-			if t.matches(nodeText, transform) {
-				newText := t.apply(nodeText, transform)
-				if newText != nodeText {
-					replacements = append(replacements, Replacement{
-						StartByte: uint32(node.StartByte()),
-						EndByte:   uint32(node.EndByte()),
-						OldText:   nodeText,
-						NewText:   newText,
-					})
-					thisNodeReplaced = true
-					break // only apply the first matching change ?
-				}
+			if newText := applyTransforms(nodeText, matching); newText != nodeText {
+				replacements = append(replacements, Replacement{
+					StartByte: uint32(node.StartByte()),
+					EndByte:   uint32(node.EndByte()),
+					OldText:   nodeText,
+					NewText:   newText,
+				})
+				thisNodeReplaced = true
 			}
 		}
 	}
 
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(uint32(i))
-		childReplacements := t.collectReplacements(&child, source, langConfig, thisNodeReplaced)
+		childQualified := []string{node.Type() + "." + child.Type()}
+		if field := node.FieldNameForChild(i); field != "" {
+			childQualified = append(childQualified, node.Type()+"."+field)
+		}
+		childReplacements := t.collectNodeReplacements(&child, source, langConfig, transforms, parentReplaced || thisNodeReplaced, childQualified)
 		replacements = append(replacements, childReplacements...)
 	}
 
 	return replacements
 }
 
-func (t *Transformer) matches(value string, transform spec.Transform) bool {
-	if transform.ExactMatch {
-		if transform.CaseSensitive {
-			return value == transform.Match
+func matchesQualified(langConfig *languages.LanguageConfig, qualified []string, categories []languages.NodeCategory) bool {
+	for _, q := range qualified {
+		if langConfig.MatchesCategory(q, categories) {
+			return true
 		}
-		return strings.EqualFold(value, transform.Match)
 	}
+	return false
+}
 
-	if transform.CaseSensitive {
-		return strings.Contains(value, transform.Match)
+// applyTransforms applies every transform to a node's text in turn, so values
+// nested in one node, such as the org and name in a Go module path, are all
+// replaced. Matches are found in the original text and skipped where an
+// earlier transform already replaced part of them, so transforms never match
+// the Jinja of another.
+func applyTransforms(text string, transforms []spec.Transform) string {
+	var spans []Replacement
+	for _, transform := range transforms {
+		for _, idx := range matchIndexes(text, transform) {
+			end := idx + len(transform.Match)
+			if transform.ExactMatch {
+				end = len(text)
+			}
+			i, _ := slices.BinarySearchFunc(spans, idx, func(r Replacement, idx int) int {
+				return int(r.StartByte) - idx
+			})
+			if i > 0 && int(spans[i-1].EndByte) > idx || i < len(spans) && int(spans[i].StartByte) < end {
+				continue
+			}
+			// each match is guarded on its own, as braces around it may sit
+			// inside the node, like the "{acme}" in Python's f"{acme}"
+			newText := guardBraces(transform.Replace, byteAt(text, idx-1), byteAt(text, end))
+			spans = slices.Insert(spans, i, Replacement{StartByte: uint32(idx), EndByte: uint32(end), NewText: newText})
+		}
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, r := range spans {
+		result.WriteString(text[last:r.StartByte])
+		result.WriteString(r.NewText)
+		last = int(r.EndByte)
 	}
-	return strings.Contains(strings.ToLower(value), strings.ToLower(transform.Match))
+	result.WriteString(text[last:])
+	return result.String()
 }
 
-func (t *Transformer) apply(value string, transform spec.Transform) string {
-	if transform.ExactMatch {
-		return transform.Replace
+// matchIndexes returns the offsets the transform replaces in s
+func matchIndexes(s string, transform spec.Transform) []int {
+	switch {
+	case transform.WholePath:
+		return wholePathIndexes(s, transform)
+	case transform.ExactMatch:
+		if transform.CaseSensitive && s == transform.Match || !transform.CaseSensitive && strings.EqualFold(s, transform.Match) {
+			return []int{0}
+		}
+		return nil
+	case transform.Match == "":
+		return nil
+	case !transform.CaseSensitive:
+		s = strings.ToLower(s)
+		transform.Match = strings.ToLower(transform.Match)
+	}
+	var indexes []int
+	for start := 0; ; {
+		idx := strings.Index(s[start:], transform.Match)
+		if idx == -1 {
+			return indexes
+		}
+		indexes = append(indexes, start+idx)
+		start += idx + len(transform.Match)
 	}
-	if transform.CaseSensitive {
-		return strings.ReplaceAll(value, transform.Match, transform.Replace)
+}
+
+// byteAt returns s[i], or 0 when i is out of range
+func byteAt(s string, i int) byte {
+	if i < 0 || i >= len(s) {
+		return 0
 	}
-	// case-insensitive replacement
-	return replaceAllCaseInsensitive(value, transform.Match, transform.Replace)
+	return s[i]
 }
 
 func replaceAllCaseInsensitive(s, old, new string) string {
@@ -153,39 +270,183 @@ func replaceAllCaseInsensitive(s, old, new string) string {
 	return result.String()
 }
 
+// wholePathIndexes returns the offsets of the transform's match in s where it
+// isn't part of a longer name. Separators such as "/", "." or "::" may follow
+// it, so sub packages still match, unless the transform is Standalone.
+func wholePathIndexes(s string, transform spec.Transform) []int {
+	name := transform.Match
+	if name == "" {
+		return nil
+	}
+	var indexes []int
+	for start := 0; ; {
+		idx := strings.Index(s[start:], name)
+		if idx == -1 {
+			return indexes
+		}
+		idx += start
+		end := idx + len(name)
+		before := idx == 0 || !isNameByte(s[idx-1]) && s[idx-1] != '.'
+		after := end == len(s) || !isNameByte(s[end])
+		if transform.Standalone {
+			before = before && (idx == 0 || s[idx-1] != '/' && s[idx-1] != '@')
+			after = after && (end == len(s) || s[end] != '/' && s[end] != '@')
+		}
+		if before && after {
+			indexes = append(indexes, idx)
+		}
+		start = idx + 1
+	}
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || b == '-' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+func replaceWholePath(s string, transform spec.Transform) string {
+	var result strings.Builder
+	last := 0
+	for _, idx := range wholePathIndexes(s, transform) {
+		if idx < last {
+			continue
+		}
+		result.WriteString(s[last:idx])
+		result.WriteString(transform.Replace)
+		last = idx + len(transform.Match)
+	}
+	result.WriteString(s[last:])
+	return result.String()
+}
+
 func (t *Transformer) TransformFile(path string, content []byte) ([]byte, bool) {
-	langConfig := languages.GetLanguageForFile(path)
+	if isNotebook(path) {
+		return t.transformNotebook(path, content)
+	}
+
+	langConfig := t.languageFor(path, content)
 
 	if langConfig == nil {
 		return content, false
 	}
 
+	transforms := t.transformsFor(path, langConfig.Name)
+
 	if langConfig.Language == nil {
-		return t.TransformPlaintext(content)
+		return t.transformPlaintext(content, transforms)
 	}
 
-	return t.Transform(content, langConfig)
+	return t.transform(content, langConfig, transforms, path)
 }
 
-func (t *Transformer) TransformPlaintext(content []byte) ([]byte, bool) {
-	result := string(content)
-	changed := false
+// languageFor picks the language of a file. Overrides from the spec win, then
+// modelines, the language table, well-known filenames and shebang lines. In
+// auto mode, go-sitter-forest detection and plaintext are the last resort.
+func (t *Transformer) languageFor(path string, content []byte) *languages.LanguageConfig {
+	for _, o := range t.overrides {
+		if spec.MatchPath(path, []string{o.pattern}) {
+			return t.table.ResolveLanguage(o.language)
+		}
+	}
 
+	if name := languages.DetectModeline(content); name != "" {
+		if lc := t.lookup(name); lc != nil {
+			return lc
+		}
+	}
+
+	if lc := t.table.GetLanguageForFile(path); lc != nil {
+		return lc
+	}
+
+	for _, name := range []string{languages.DetectWellKnownFilename(path), languages.DetectShebang(content)} {
+		if name == "" {
+			continue
+		}
+		if lc := t.lookup(name); lc != nil {
+			return lc
+		}
+	}
+
+	if !t.autoLanguages {
+		return nil
+	}
+	if lc := languages.DetectLanguage(path); lc != nil {
+		return lc
+	}
+	if isText(content) {
+		return t.table.Languages["plaintext"]
+	}
+	return nil
+}
+
+// lookup resolves a detected language name. Languages outside the table are
+// only used in auto mode.
+func (t *Transformer) lookup(name string) *languages.LanguageConfig {
+	if t.autoLanguages {
+		return t.table.ResolveLanguage(name)
+	}
+	return t.table.Lookup(name)
+}
+
+// isText reports whether content looks like text rather than binary data
+func isText(content []byte) bool {
+	return utf8.Valid(content) && !bytes.Contains(content, []byte{0})
+}
+
+// transformsFor returns the transforms scoped to the given path and language
+func (t *Transformer) transformsFor(path, language string) []spec.Transform {
+	var scoped []spec.Transform
 	for _, transform := range t.transforms {
-		var newResult string
-		if transform.CaseSensitive {
-			if strings.Contains(result, transform.Match) {
-				newResult = strings.ReplaceAll(result, transform.Match, transform.Replace)
-			} else {
-				newResult = result
-			}
-		} else {
-			newResult = replaceAllCaseInsensitive(result, transform.Match, transform.Replace)
+		if transform.AppliesTo(path, language) {
+			scoped = append(scoped, transform)
+		}
+	}
+	return scoped
+}
+
+func (t *Transformer) TransformPlaintext(content []byte) ([]byte, bool) {
+	return t.transformPlaintext(content, t.transforms)
+}
+
+func (t *Transformer) transformPlaintext(content []byte, transforms []spec.Transform) ([]byte, bool) {
+	regions := t.collectRegions(nil, content)
+	if len(regions) == 0 {
+		result := replacePlaintext(string(content), transforms)
+		return []byte(result), result != string(content)
+	}
+
+	// transforms apply to the text around and inside the regions, so they
+	// never rewrite the loop tags wrapped around them
+	slices.SortFunc(regions, func(a, b region) int {
+		return int(a.start) - int(b.start)
+	})
+	var replacements []Replacement
+	replace := func(start, end uint32) {
+		oldText := string(content[start:end])
+		if newText := replacePlaintext(oldText, transforms); newText != oldText {
+			replacements = append(replacements, Replacement{StartByte: start, EndByte: end, OldText: oldText, NewText: newText})
 		}
-		if newResult != result {
-			changed = true
-			result = newResult
+	}
+	last := uint32(0)
+	for _, reg := range regions {
+		replace(last, reg.start)
+		replace(reg.start, reg.end)
+		last = reg.end
+	}
+	replace(last, uint32(len(content)))
+	return applyReplacements(content, wrapRegions(content, regions, replacements)), true
+}
+
+// replacePlaintext applies transforms to text without a grammar
+func replacePlaintext(result string, transforms []spec.Transform) string {
+	for _, transform := range transforms {
+		if transform.WholePath {
+			result = replaceWholePath(result, transform)
+		} else if transform.CaseSensitive {
+			result = strings.ReplaceAll(result, transform.Match, transform.Replace)
+		} else {
+			result = replaceAllCaseInsensitive(result, transform.Match, transform.Replace)
 		}
 	}
-	return []byte(result), changed
+	return result
 }
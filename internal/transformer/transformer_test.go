@@ -0,0 +1,276 @@
+package transformer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tnaucoin/mintmpl/internal/languages"
+	"github.com/tnaucoin/mintmpl/internal/spec"
+)
+
+func TestScopedTransforms(t *testing.T) {
+	trans := New([]spec.Transform{{
+		Match:         "Acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryNamespace, languages.CategoryString},
+		CaseSensitive: true,
+		ExcludePaths:  []string{"wwwroot/lib/"},
+		Languages:     []string{"csharp", "javascript"},
+	}}, nil)
+	tests := []struct {
+		path, source, want string
+	}{
+		{"Program.cs", "namespace Acme.Web;\n", "namespace {{ project_name }}.Web;\n"},
+		{"wwwroot/app.js", "const name = \"Acme\";\n", "const name = \"{{ project_name }}\";\n"},
+		// vendored code and other languages are left alone
+		{"wwwroot/lib/acme.js", "const name = \"Acme\";\n", "const name = \"Acme\";\n"},
+		{"appsettings.json", "{\"name\": \"Acme\"}\n", "{\"name\": \"Acme\"}\n"},
+	}
+	for _, tt := range tests {
+		got, _ := trans.TransformFile(tt.path, []byte(tt.source))
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetLanguages(t *testing.T) {
+	table := languages.Builtin()
+	table.Languages["json"].OverrideCategory(languages.CategoryIdentifier, []string{"string_content"}, nil)
+	transform := spec.Transform{
+		Match:         "acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryIdentifier},
+		CaseSensitive: true,
+	}
+	source := []byte("{\"name\": \"acme\"}\n")
+
+	trans := New([]spec.Transform{transform}, nil)
+	if _, changed := trans.TransformFile("package.json", source); changed {
+		t.Error("built-in json has no identifiers, yet the file changed")
+	}
+	trans.SetLanguages(table)
+	got, _ := trans.TransformFile("package.json", source)
+	if want := "{\"name\": \"{{ project_name }}\"}\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAutoLanguages(t *testing.T) {
+	transform := spec.Transform{
+		Match:         "acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryString},
+		CaseSensitive: true,
+	}
+	tests := []struct {
+		path, source, want string
+	}{
+		// a grammar is picked from go-sitter-forest
+		{"build.zig", "const name = \"acme\";\n", "const name = \"{{ project_name }}\";\n"},
+		// other text files are handled as plaintext
+		{"notes.unknown", "acme notes\n", "{{ project_name }} notes\n"},
+		// binary files are left alone
+		{"logo.unknown", "acme\x00\x01", "acme\x00\x01"},
+	}
+	for _, tt := range tests {
+		trans := New([]spec.Transform{transform}, nil)
+		if _, changed := trans.TransformFile(tt.path, []byte(tt.source)); changed {
+			t.Errorf("%s changed without auto languages", tt.path)
+		}
+		trans.SetAutoLanguages(true)
+		got, _ := trans.TransformFile(tt.path, []byte(tt.source))
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageDetection(t *testing.T) {
+	trans := New([]spec.Transform{{
+		Match:         "acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryString},
+		CaseSensitive: true,
+	}}, nil)
+	trans.SetLanguageOverrides(map[string]string{"*.tmpl": "go"})
+	tests := []struct {
+		name, path, source, want string
+	}{
+		{
+			name:   "override",
+			path:   "main.tmpl",
+			source: "package acme\n\nconst name = \"acme\"\n",
+			want:   "package acme\n\nconst name = \"{{ project_name }}\"\n",
+		},
+		{
+			name:   "modeline",
+			path:   "settings.conf",
+			source: "# vim: ft=python\nNAME = \"acme\"  # acme\n",
+			want:   "# vim: ft=python\nNAME = \"{{ project_name }}\"  # acme\n",
+		},
+		{
+			name:   "shebang",
+			path:   "bin/manage",
+			source: "#!/usr/bin/env python3\nNAME = \"acme\"  # acme\n",
+			want:   "#!/usr/bin/env python3\nNAME = \"{{ project_name }}\"  # acme\n",
+		},
+		{
+			name:   "unknown",
+			path:   "bin/run",
+			source: "#!/opt/acme/run\nacme\n",
+			want:   "#!/opt/acme/run\nacme\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := trans.TransformFile(tt.path, []byte(tt.source))
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplacedNodeClaimsDescendants(t *testing.T) {
+	trans := New([]spec.Transform{{
+		Match:         "Acme",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryClass},
+		CaseSensitive: true,
+	}}, nil)
+	// the nested class sits below the outer class's body, not directly
+	// under the outer class; matching it again overlapped the outer
+	// replacement and garbled the output
+	source := "class AcmeClient\n{\n    class AcmeOptions { }\n}\n"
+	got, _ := trans.TransformFile("Client.cs", []byte(source))
+	if want := "class {{ project_name }}Client\n{\n    class {{ project_name }}Options { }\n}\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInterpolationsStayValidJinja(t *testing.T) {
+	tests := []struct {
+		path   string
+		cat    languages.NodeCategory
+		source string
+		want   string
+	}{
+		{"Main.kt", languages.CategoryIdentifier, "val s = \"${acme.x}\"\n", "val s = \"${ {{ project_name }}.x}\"\n"},
+		{"build.gradle", languages.CategoryIdentifier, "def s = \"${acme.x}\"\n", "def s = \"${ {{ project_name }}.x}\"\n"},
+		{"app.rb", languages.CategoryIdentifier, "s = \"#{acme}\"\n", "s = \"#{ {{ project_name }} }\"\n"},
+		{"app.ex", languages.CategoryIdentifier, "s = \"#{acme}\"\n", "s = \"#{ {{ project_name }} }\"\n"},
+		// the braces sit inside the replaced string node
+		{"app.py", languages.CategoryString, "s = f\"{acme}\"\n", "s = f\"{ {{ project_name }} }\"\n"},
+		{"app.js", languages.CategoryString, "const s = `acme: ${acme}!`;\n", "const s = `{{ project_name }}: ${ {{ project_name }} }!`;\n"},
+		{"app.ts", languages.CategoryString, "const s = `${acme}-${acme.id}`;\n", "const s = `${ {{ project_name }} }-${ {{ project_name }}.id}`;\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			trans := New([]spec.Transform{{
+				Match:         "acme",
+				Replace:       "{{ project_name }}",
+				NodeTypes:     []languages.NodeCategory{tt.cat},
+				CaseSensitive: true,
+			}}, nil)
+			got, _ := trans.TransformFile(tt.path, []byte(tt.source))
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if strings.Contains(string(got), "{{{") || strings.Contains(string(got), "}}}") {
+				t.Errorf("%q joins braces with Jinja delimiters", got)
+			}
+		})
+	}
+}
+
+func TestNestedValuesInOneNode(t *testing.T) {
+	org := spec.Transform{
+		Match:         "acme",
+		Replace:       "{{ org }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryNamespace},
+		CaseSensitive: true,
+	}
+	name := spec.Transform{
+		Match:         "widget",
+		Replace:       "{{ project_name }}",
+		NodeTypes:     []languages.NodeCategory{languages.CategoryNamespace},
+		CaseSensitive: true,
+	}
+	source := []byte("module github.com/acme/widget\n\ngo 1.22\n")
+	want := "module github.com/{{ org }}/{{ project_name }}\n\ngo 1.22\n"
+	for _, transforms := range [][]spec.Transform{{org, name}, {name, org}} {
+		got, _ := New(transforms, nil).TransformFile("go.mod", source)
+		if string(got) != want {
+			t.Errorf("with %q first got %q, want %q", transforms[0].Match, got, want)
+		}
+	}
+
+	// a transform never matches the Jinja of another
+	o := spec.Transform{Match: "o", Replace: "{{ letter }}", NodeTypes: org.NodeTypes, CaseSensitive: true}
+	got, _ := New([]spec.Transform{org, o}, nil).TransformFile("go.mod", source)
+	if want := "module github.c{{ letter }}m/{{ org }}/widget\n\ngo 1.22\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOverlappingMatches(t *testing.T) {
+	transform := func(match, replace string) spec.Transform {
+		return spec.Transform{
+			Match:         match,
+			Replace:       replace,
+			NodeTypes:     []languages.NodeCategory{languages.CategoryString},
+			CaseSensitive: true,
+		}
+	}
+	slug := transform("acme-widget", "{{ project_slug }}")
+	org := transform("acme", "{{ org }}")
+	api := transform("widget-api", "{{ api }}")
+	tests := []struct {
+		name       string
+		transforms []spec.Transform
+		source     string
+		want       string
+	}{
+		{
+			// the longer match claims its text, the shorter one still
+			// matches elsewhere in the node
+			name:       "substring",
+			transforms: []spec.Transform{slug, org},
+			source:     `"acme-widget by acme"`,
+			want:       `"{{ project_slug }} by {{ org }}"`,
+		},
+		{
+			name:       "overlap",
+			transforms: []spec.Transform{slug, api},
+			source:     `"acme-widget-api"`,
+			want:       `"{{ project_slug }}-api"`,
+		},
+		{
+			name:       "adjacent",
+			transforms: []spec.Transform{org, transform("widget", "{{ name }}")},
+			source:     `"acmewidget"`,
+			want:       `"{{ org }}{{ name }}"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "package main\n\nvar s = " + tt.source + "\n"
+			got, _ := New(tt.transforms, nil).TransformFile("main.go", []byte(source))
+			if want := "package main\n\nvar s = " + tt.want + "\n"; string(got) != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCountOccurrencesOfNestedValues(t *testing.T) {
+	source := []byte("module github.com/acme/acme-widget\n")
+	counts := make(map[string]int)
+	for _, o := range New(nil, nil).CountOccurrences("go.mod", source, []string{"acme", "acme-widget"}) {
+		counts[o.Value] += o.Count
+	}
+	if counts["acme"] != 1 || counts["acme-widget"] != 1 {
+		t.Errorf("got %v, want acme and acme-widget once each", counts)
+	}
+}